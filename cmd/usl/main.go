@@ -3,10 +3,18 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/fatih/color"
 
 	"github.com/alaturka/gbreve/net/usl"
+	"github.com/alaturka/gbreve/os/osutil"
 	"github.com/alaturka/gbreve/text/textutil"
 )
 
@@ -15,9 +23,45 @@ var (
 	build   string //nolint
 )
 
+type command struct {
+	run   func(o *options, args ...string)
+	nargs int
+	args  string
+	usage string
+}
+
+var commands = map[string]command{
+	"attributes": {runAttributes, 0, "", "Describe the attributes of USLs"},
+	"from-path":  {runFromPath, 1, "DIR [attributes...]", "Map a local directory in the layout back to a USL"},
+	"explain":    {runExplain, 1, "USL [attributes...]", "Explain the decisions made while parsing a USL"},
+	"go":         {runGo, 1, "IMPORTPATH [attributes...]", "Resolve a Go import path to its repository"},
+	"lint":       {runLint, 1, "USL...", "Report problems found in USLs, exit with failure on errors"},
+	"path":       {runPath, 1, "USL", "Print the local directory of a USL in the layout"},
+	"purl":       {runPURL, 1, "USL|PURL", "Convert a USL to a Package URL or vice versa"},
+}
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s USL [flags...] [attributes...]\n\n", os.Args[0])
-	fmt.Fprintf(os.Stderr, "Flags:\n")
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags...] USL [attributes...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [flags...] COMMAND [arguments...]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+
+	names := make([]string, 0, len(commands))
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", name, commands[name].args, commands[name].usage)
+	}
+
+	w.Flush()
+
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
 
 	flag.PrintDefaults()
 
@@ -34,21 +78,29 @@ func die(message ...interface{}) {
 	os.Exit(1)
 }
 
-func wanted(defaultAttributes []string, attributes ...string) []string {
-	if len(attributes) > 0 {
-		return attributes
+func wanted(m map[string]string, defaultAttributes []string, attributes ...string) []string {
+	if len(attributes) == 0 {
+		return defaultAttributes
+	}
+
+	for _, attribute := range attributes {
+		if _, ok := usl.LookupAttribute(attribute); ok {
+			continue
+		}
+
+		if _, ok := m[attribute]; !ok {
+			die(fmt.Sprintf("unknown attribute %q, see the attributes command", attribute))
+		}
 	}
 
-	return defaultAttributes
+	return attributes
 }
 
 // Print should be commented
-func Print(us *usl.USL, templateMap map[string]string, attributes ...string) {
-	m, ks := us.MapCustom(templateMap)
-
+func Print(m map[string]string, ks []string, attributes ...string) {
 	var pairs []string
 
-	for _, attribute := range wanted(ks, attributes...) {
+	for _, attribute := range wanted(m, ks, attributes...) {
 		if value, ok := m[attribute]; ok {
 			s := fmt.Sprintf("%s='%s'", attribute, value)
 			pairs = append(pairs, s)
@@ -59,14 +111,12 @@ func Print(us *usl.USL, templateMap map[string]string, attributes ...string) {
 }
 
 // Bash should be commented
-func Bash(variable string, us *usl.USL, templateMap map[string]string, attributes ...string) {
-	m, ks := us.MapCustom(templateMap)
-
+func Bash(variable string, m map[string]string, ks []string, attributes ...string) {
 	var pairs []string
 
 	pairs = append(pairs, variable+"=(")
 
-	for _, attribute := range wanted(ks, attributes...) {
+	for _, attribute := range wanted(m, ks, attributes...) {
 		if value, ok := m[attribute]; ok {
 			s := fmt.Sprintf("[%s]='%s'", attribute, value)
 			pairs = append(pairs, s)
@@ -78,6 +128,20 @@ func Bash(variable string, us *usl.USL, templateMap map[string]string, attribute
 	fmt.Println(strings.Join(pairs, " "))
 }
 
+// Render prints the given rendered template or attribute alone, ending with a newline
+func Render(m map[string]string, name string) {
+	value, ok := m[name]
+	if !ok {
+		die(fmt.Sprintf("no such template or attribute to render: %s", name))
+	}
+
+	if !strings.HasSuffix(value, "\n") {
+		value += "\n"
+	}
+
+	fmt.Print(value)
+}
+
 type varFlags []string
 
 func (v *varFlags) String() string {
@@ -90,14 +154,238 @@ func (v *varFlags) Set(value string) error {
 	return nil
 }
 
-func main() {
-	var variables varFlags
+type options struct {
+	allowLocalPath bool
+	inspectGit     bool
+	upstream       bool
+	unicode        bool
+	sandbox        bool
+	bashArray      string
+	render         string
+	layout         usl.Layout
+	root           string
+	templateMap    map[string]string
+	funcs          template.FuncMap
+}
+
+// localRules returns the rules of local paths, or nil if local paths are not allowed.
+func (o *options) localRules() *usl.LocalRules {
+	if !o.allowLocalPath && !o.inspectGit {
+		return nil
+	}
+
+	rules := *usl.DefaultLocalRules
+	rules.Git = o.inspectGit
+	rules.Upstream = o.upstream
+
+	return &rules
+}
+
+func (o *options) parse(rawurl string) *usl.USL {
+	parser := usl.Parse
+
+	if rules := o.localRules(); rules != nil {
+		parser = rules.ParseMayLocalPath
+	}
+
+	us, err := parser(rawurl)
+	if err != nil {
+		die(err)
+	}
+
+	if o.unicode {
+		us = us.Unicode()
+	}
+
+	return us
+}
+
+func (o *options) print(us *usl.USL, attributes ...string) {
+	var (
+		m   map[string]string
+		ks  []string
+		err error
+	)
+
+	if o.sandbox {
+		m, ks, err = us.MapSandboxed(textutil.NewSandbox(), o.templateMap, o.funcs)
+	} else {
+		m, ks, err = us.MapCustom(o.templateMap, o.funcs)
+	}
+
+	if err != nil {
+		die(err)
+	}
+
+	switch {
+	case o.render != "":
+		Render(m, o.render)
+	case o.bashArray != "":
+		Bash(o.bashArray, m, ks, attributes...)
+	default:
+		Print(m, ks, attributes...)
+	}
+}
+
+func runDefault(o *options, args ...string) {
+	o.print(o.parse(args[0]), args[1:]...)
+}
+
+func runAttributes(_ *options, _ ...string) {
+	for _, a := range usl.Attributes() {
+		description := a.Description
+		if a.Computed {
+			description += " (computed)"
+		}
+
+		fmt.Printf("%-10s %s\n", a.Name, description)
+	}
+}
+
+func runExplain(o *options, args ...string) {
+	color.NoColor = !osutil.IsTerminal()
+
+	matched, unmatched := color.New(color.FgGreen, color.Bold), color.New(color.Faint)
+
+	explain := usl.ParseExplain
+
+	if rules := o.localRules(); rules != nil {
+		explain = rules.ParseExplainMayLocalPath
+	}
+
+	us, steps, err := explain(args[0])
+
+	for _, step := range steps {
+		if step.Matched {
+			fmt.Printf("%s %s\n", matched.Sprintf("+ %-22s", step.Rule), step.Detail)
+		} else {
+			fmt.Printf("%s %s\n", unmatched.Sprintf("- %-22s", step.Rule), unmatched.Sprint(step.Detail))
+		}
+	}
+
+	if err != nil {
+		die(color.RedString("%v", err))
+	}
+
+	fmt.Println()
+
+	o.print(us, args[1:]...)
+}
+
+func runFromPath(o *options, args ...string) {
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		die(err)
+	}
+
+	us, err := o.layout.Parse(o.rootDir(), dir)
+	if err != nil {
+		die(err)
+	}
+
+	o.print(us, args[1:]...)
+}
+
+func runGo(o *options, args ...string) {
+	us, err := usl.ResolveGoImport(args[0])
+	if err != nil {
+		die(err)
+	}
+
+	o.print(us, args[1:]...)
+}
+
+func runLint(_ *options, args ...string) {
+	failed := false
+
+	for _, in := range args {
+		_, ds := usl.Lint(in)
+
+		for _, d := range ds {
+			fmt.Printf("%s: %s\n", in, d)
+		}
+
+		if ds.HasErrors() {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runPath(o *options, args ...string) {
+	dir, err := o.layout.Path(o.rootDir(), o.parse(args[0]))
+	if err != nil {
+		die(err)
+	}
+
+	fmt.Println(dir)
+}
+
+func runPURL(o *options, args ...string) {
+	in := args[0]
+
+	if strings.HasPrefix(in, "pkg:") {
+		us, err := usl.ParsePURL(in)
+		if err != nil {
+			die(err)
+		}
+
+		o.print(us, args[1:]...)
+
+		return
+	}
+
+	purl, err := o.parse(in).PURL()
+	if err != nil {
+		die(err)
+	}
+
+	fmt.Println(purl)
+}
+
+// rootDir returns the root directory of local checkouts, which defaults to GOPATH (or ~/go) for the GOPATH layout and
+// ~/src for the others.
+func (o *options) rootDir() string {
+	if o.root != "" {
+		return o.root
+	}
+
+	if gopath := os.Getenv("GOPATH"); o.layout == usl.LayoutGOPATH && gopath != "" {
+		return filepath.SplitList(gopath)[0]
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		die(err)
+	}
+
+	if o.layout == usl.LayoutGOPATH {
+		return filepath.Join(home, "go")
+	}
+
+	return filepath.Join(home, "src")
+}
+
+func main() { //nolint:funlen
+	var variables, envs varFlags
 
 	flag.Usage = usage
 
 	allowLocalPath := flag.Bool("local", false, "Allow local paths while parsing.")
+	inspectGit := flag.Bool("git", false, "Inspect local git working trees, implies -local.")
+	upstream := flag.Bool("upstream", false, "Include the upstream remote of local git working trees, implies -git.")
+	unicode := flag.Bool("unicode", false, "Display internationalized domain names in Unicode.")
+	sandbox := flag.Bool("sandbox", false, "Render variable templates in a sandbox with restricted functions and limits.")
 	bashArray := flag.String("bash", "", "Print result as a Bash associated array with the given name.")
-	flag.Var(&variables, "var", `Set variable template as 'variable="template"'.`)
+	templateFile := flag.String("template-file", "", "Read variable templates from a file of defines or assignments.")
+	render := flag.String("render", "", "Print only the given rendered template or attribute.")
+	layoutName := flag.String("layout", string(usl.LayoutGhq), "Layout of local checkouts: ghq, gopath, flat or id.")
+	root := flag.String("root", "", "Root directory of local checkouts, defaults to ~/src or GOPATH.")
+	flag.Var(&variables, "var", `Set variable template as 'variable="template"', quoting templates with spaces.`)
+	flag.Var(&envs, "env", "Allow templates to look up the given environment variable with env.")
 
 	flag.Parse()
 
@@ -107,24 +395,28 @@ func main() {
 
 	args := flag.Args()
 
-	parser := usl.Parse
-	if *allowLocalPath {
-		parser = usl.ParseMayLocalPath
-	}
+	templateMap := map[string]string{}
 
-	us, err := parser(args[0])
-	if err != nil {
-		die(err)
-	}
+	funcs := template.FuncMap{"env": textutil.Env(envs...)}
 
-	templateMap := map[string]string{}
+	if *templateFile != "" {
+		content, err := ioutil.ReadFile(*templateFile)
+		if err != nil {
+			die(err)
+		}
+
+		if templateMap, err = textutil.ParseTemplates(*templateFile, string(content), funcs); err != nil {
+			die(err)
+		}
+	}
 
 	for _, expr := range variables {
 		kv := map[string]string{}
 		err := textutil.ParseAssignment(expr, kv)
 
 		if err != nil {
-			die(err)
+			die(fmt.Sprintf(`-var %q: %v (templates with spaces should be quoted, e.g. 'v="{{ .owner }} {{ .repo }}"')`,
+				expr, err))
 		}
 
 		for k, v := range kv {
@@ -132,9 +424,36 @@ func main() {
 		}
 	}
 
-	if *bashArray != "" {
-		Bash(*bashArray, us, templateMap, args[1:]...)
-	} else {
-		Print(us, templateMap, args[1:]...)
+	layout, err := usl.ParseLayout(*layoutName)
+	if err != nil {
+		die(err)
+	}
+
+	o := &options{
+		allowLocalPath: *allowLocalPath,
+		inspectGit:     *inspectGit || *upstream,
+		upstream:       *upstream,
+		unicode:        *unicode,
+		sandbox:        *sandbox,
+		bashArray:      *bashArray,
+		render:         *render,
+		layout:         layout,
+		root:           *root,
+		templateMap:    templateMap,
+		funcs:          funcs,
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		runDefault(o, args...)
+
+		return
 	}
+
+	if len(args) <= cmd.nargs {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags...] %s %s\n", os.Args[0], args[0], cmd.args)
+		os.Exit(2)
+	}
+
+	cmd.run(o, args[1:]...)
 }
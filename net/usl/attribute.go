@@ -0,0 +1,111 @@
+package usl
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Attribute describes an attribute of USLs available in Map and templates.
+type Attribute struct {
+	Name        string               // Lower case name, e.g. "basepath"
+	Description string               // Human readable description
+	Get         func(us *USL) string // Getter of the value
+	Computed    bool                 // Whether the value is computed on demand rather than stored in a field
+}
+
+// Registry of attributes sorted by name
+var attributes = []Attribute{
+	{"account", "Storage account of Azure objects", func(us *USL) string { return us.Account }, false},
+	{"basepath", "URL path without leading and trailing slashes", func(us *USL) string { return us.BasePath }, false},
+	{"bucket", "Bucket (or container) of objects", func(us *USL) string { return us.Bucket }, false},
+	{"class", "Source class, e.g. git or tar.gz", func(us *USL) string { return us.Class.String() }, false},
+	{"commit", "Current commit of local git working trees", func(us *USL) string { return us.Commit }, false},
+	{"digest", "Content digest of OCI artifacts", func(us *USL) string { return us.Digest }, false},
+	{"domain", "URL host without port", func(us *USL) string { return us.Domain }, false},
+	{"fragment", "URL fragment", func(us *USL) string { return us.Fragment }, false},
+	{"host", "URL host", func(us *USL) string { return us.Host }, false},
+	{"id", "Source identifier", func(us *USL) string { return us.ID }, false},
+	{"inpath", "Relative path after root source", func(us *USL) string { return us.InPath }, false},
+	{"key", "Key of objects", func(us *USL) string { return us.Key }, false},
+	{"name", "Name of the source in relative path form", func(us *USL) string { return us.Name }, false},
+	{"namespace", "Alias of owner", func(us *USL) string { return us.Owner }, true},
+	{"owner", "All but the last segment of name, i.e. user or (nested) group", func(us *USL) string {
+		return us.Owner
+	}, false},
+	{"password", "URL userinfo password", func(us *USL) string { return us.Password }, false},
+	{"path", "URL path", func(us *USL) string { return us.Path }, false},
+	{"port", "URL port", func(us *USL) string { return us.Port }, false},
+	{"ref", "Version control reference (i.e. branch, tag, commit) or OCI tag", func(us *USL) string {
+		return us.Ref
+	}, false},
+	{"refkind", "Kind of git references, i.e. symbolic name or object ID", func(us *USL) string {
+		return string(us.RefKind)
+	}, false},
+	{"region", "Region of objects", func(us *USL) string { return us.Region }, false},
+	{"repo", "Last segment of name, i.e. short name of the repository", func(us *USL) string { return us.Repo }, false},
+	{"scheme", "URL scheme", func(us *USL) string { return us.Scheme }, false},
+	{"slug", "File name unique for the domain and the name", func(us *USL) string { return us.Slug }, false},
+	{"source", "Transport string", func(us *USL) string { return us.Source }, false},
+	{"upstream", "Upstream remote USL of local git working trees", func(us *USL) string { return us.Upstream }, false},
+	{"username", "URL userinfo username", func(us *USL) string { return us.Username }, false},
+	{"version", "Version (or generation) of objects", func(us *USL) string { return us.Version }, false},
+	{"weburl", "Web page of the source, if any", (*USL).WebURL, true},
+}
+
+// Attributes returns the attributes of USLs sorted by name.
+func Attributes() []Attribute {
+	return append([]Attribute(nil), attributes...)
+}
+
+// LookupAttribute returns the attribute with the given name.
+func LookupAttribute(name string) (Attribute, bool) {
+	for _, a := range attributes {
+		if a.Name == name {
+			return a, true
+		}
+	}
+
+	return Attribute{}, false
+}
+
+// Paths of the pages browsing a tree at a reference for each provider
+var webTreePaths = map[string]string{
+	"bitbucket.org":    "/src/",
+	"github.com":       "/tree/",
+	"gitlab.com":       "/-/tree/",
+	"salsa.debian.org": "/-/tree/",
+}
+
+// WebURL returns the web page of the source.  Pages of git repositories on the supported providers point to the
+// reference and the path in repository if given, otherwise the web page is the source itself for HTTP sources without
+// the credentials.  An empty string is returned if no web page is known.
+func (us *USL) WebURL() string {
+	if treePath, ok := webTreePaths[us.Host]; ok && us.Class == "git" {
+		u := "https://" + us.Host + "/" + us.Name
+
+		if us.Ref == "" && us.InPath == "" {
+			return u
+		}
+
+		ref := us.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+
+		return u + treePath + (&url.URL{Path: strings.TrimSuffix(ref+"/"+us.InPath, "/")}).EscapedPath()
+	}
+
+	if us.Scheme != "https" && us.Scheme != "http" {
+		return ""
+	}
+
+	u, err := url.Parse(us.Source)
+	if err != nil {
+		return ""
+	}
+
+	u.Scheme = us.Scheme
+	u.User = nil
+
+	return u.String()
+}
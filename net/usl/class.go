@@ -0,0 +1,198 @@
+package usl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Class is the kind of a source, i.e. a version control system, an archive, a compressed file or an OCI artifact.
+type Class string
+
+// Container formats
+const (
+	ContainerTar = "tar"
+	ContainerZip = "zip"
+	Container7z  = "7z"
+)
+
+// Compression formats
+const (
+	CompressionBzip2 = "bzip2"
+	CompressionGzip  = "gzip"
+	CompressionLzip  = "lzip"
+	CompressionXz    = "xz"
+	CompressionZstd  = "zstd"
+)
+
+type classInfo struct {
+	container   string
+	compression string
+	vcs         bool
+	schemes     []string               // Schemes native to the version control system
+	ref         func(ref string) error // Reference validator of the version control system
+}
+
+var classInfos = map[Class]classInfo{
+	"bzr":    {vcs: true, schemes: []string{"bzr", "bzr+ssh"}, ref: validBazaarRef},
+	"fossil": {vcs: true, ref: validFossilRef},
+	"git":    {vcs: true, ref: validGitRef},
+	"hg":     {vcs: true, ref: validMercurialRef},
+	"svn":    {vcs: true, schemes: []string{"svn", "svn+ssh"}, ref: validSubversionRef},
+
+	"oci": {ref: validOCITag},
+
+	"tar":     {container: ContainerTar},
+	"tar.bz2": {container: ContainerTar, compression: CompressionBzip2},
+	"tar.gz":  {container: ContainerTar, compression: CompressionGzip},
+	"tar.lz":  {container: ContainerTar, compression: CompressionLzip},
+	"tar.xz":  {container: ContainerTar, compression: CompressionXz},
+	"tar.zst": {container: ContainerTar, compression: CompressionZstd},
+	"tbz2":    {container: ContainerTar, compression: CompressionBzip2},
+	"tgz":     {container: ContainerTar, compression: CompressionGzip},
+	"txz":     {container: ContainerTar, compression: CompressionXz},
+
+	"7z":  {container: Container7z},
+	"zip": {container: ContainerZip},
+
+	"bz2": {compression: CompressionBzip2},
+	"gz":  {compression: CompressionGzip},
+	"lz":  {compression: CompressionLzip},
+	"xz":  {compression: CompressionXz},
+	"zst": {compression: CompressionZstd},
+}
+
+// String returns the class name which is also the file extension for archives.
+func (c Class) String() string {
+	return string(c)
+}
+
+// IsVCS reports whether the class is a version control system.
+func (c Class) IsVCS() bool {
+	return classInfos[c].vcs
+}
+
+// IsArchive reports whether the class is a container format holding multiple files (e.g. tar, zip).
+func (c Class) IsArchive() bool {
+	return classInfos[c].container != ""
+}
+
+// IsCompressed reports whether the class involves a compression (e.g. tar.gz, xz).
+func (c Class) IsCompressed() bool {
+	return classInfos[c].compression != ""
+}
+
+// Container returns the container format of an archive class, or an empty string if the class is not an archive.
+func (c Class) Container() string {
+	return classInfos[c].container
+}
+
+// Compression returns the compression format of the class, or an empty string if the class is not compressed.
+func (c Class) Compression() string {
+	return classInfos[c].compression
+}
+
+// ValidateRef checks the given reference against the reference semantics of the class.
+func (c Class) ValidateRef(ref string) error {
+	info := classInfos[c]
+
+	if info.ref == nil {
+		if c == "" {
+			return fmt.Errorf("reference found for unclassified source: %q", ref)
+		}
+
+		return fmt.Errorf("reference found for non version controlled source of class %q: %q", c, ref)
+	}
+
+	return info.ref(ref)
+}
+
+// vcsSchemeOf returns the scheme joining the version control system of the class with the given transport scheme,
+// e.g. "hg+https" for "hg" and "https", which is the transport scheme itself for git and the native schemes.
+func vcsSchemeOf(c Class, transport string) string {
+	if !c.IsVCS() || c == "git" || transport == "" {
+		return transport
+	}
+
+	for _, native := range classInfos[c].schemes {
+		if transport == native {
+			return transport
+		}
+	}
+
+	return c.String() + "+" + transport
+}
+
+// vcsScheme splits the given scheme into a version control system class and a transport scheme; such as
+// "hg+https" into "hg" and "https".  Schemes native to a version control system (e.g. "svn+ssh") are kept intact.
+func vcsScheme(scheme string) (Class, string, bool) {
+	for class, info := range classInfos {
+		for _, native := range info.schemes {
+			if scheme == native {
+				return class, scheme, true
+			}
+		}
+	}
+
+	vcs, transport := cut(scheme, "+")
+	if class := Class(vcs); transport != "" && class.IsVCS() {
+		return class, transport, true
+	}
+
+	return "", scheme, false
+}
+
+func validGitRef(ref string) error {
+	return CheckRefFormat(ref)
+}
+
+var (
+	reSubversionRef = regexp.MustCompile(`^(r?[0-9]+|HEAD|BASE|COMMITTED|PREV|\{[^{}]+\})$`)
+	reBazaarRef     = regexp.MustCompile(`^(-?[0-9]+(\.[0-9]+)*|[a-z]+:.+)$`)
+)
+
+func validSubversionRef(ref string) error {
+	if !reSubversionRef.MatchString(ref) {
+		return fmt.Errorf("invalid subversion revision %q, must be a number, a {date} or a revision keyword", ref)
+	}
+
+	return nil
+}
+
+func validMercurialRef(ref string) error {
+	if ref == "" || strings.ContainsAny(ref, ":\r\n\x00") {
+		return fmt.Errorf("invalid mercurial changeset, bookmark, branch or tag name %q", ref)
+	}
+
+	return nil
+}
+
+func validFossilRef(ref string) error {
+	if ref == "" || strings.IndexFunc(ref, isSpaceOrControl) >= 0 {
+		return fmt.Errorf("invalid fossil check-in, branch or tag name %q", ref)
+	}
+
+	return nil
+}
+
+func validBazaarRef(ref string) error {
+	if !reBazaarRef.MatchString(ref) {
+		return fmt.Errorf("invalid bazaar revision %q, must be a revision number or a prefixed revision spec", ref)
+	}
+
+	return nil
+}
+
+var reOCITag = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+
+func validOCITag(ref string) error {
+	if !reOCITag.MatchString(ref) {
+		return fmt.Errorf("invalid oci tag %q", ref)
+	}
+
+	return nil
+}
+
+func isSpaceOrControl(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
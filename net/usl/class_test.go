@@ -0,0 +1,228 @@
+package usl
+
+import (
+	"testing"
+)
+
+type testClass struct {
+	in          string
+	class       Class
+	name        string
+	inpath      string
+	container   string
+	compression string
+}
+
+//nolint:funlen
+func TestClass(t *testing.T) {
+	t.Parallel()
+
+	tests := []testClass{
+		{"https://example.com/a/b.tar", "tar", "a/b", "", ContainerTar, ""},
+		{"https://example.com/a/b.tar.gz/x", "tar.gz", "a/b", "x", ContainerTar, CompressionGzip},
+		{"https://example.com/a/b.tar.zst", "tar.zst", "a/b", "", ContainerTar, CompressionZstd},
+		{"https://example.com/a/b.tar.lz/x/y", "tar.lz", "a/b", "x/y", ContainerTar, CompressionLzip},
+		{"https://example.com/a/b.tbz2", "tbz2", "a/b", "", ContainerTar, CompressionBzip2},
+		{"https://example.com/a/b.txz", "txz", "a/b", "", ContainerTar, CompressionXz},
+		{"https://example.com/a/b.7z/x", "7z", "a/b", "x", Container7z, ""},
+		{"https://example.com/a/b.zip", "zip", "a/b", "", ContainerZip, ""},
+		{"https://example.com/a/b.gz", "gz", "a/b", "", "", CompressionGzip},
+		{"https://example.com/a/b.xz", "xz", "a/b", "", "", CompressionXz},
+		{"https://example.com/a/b-1.2.tar.xz", "tar.xz", "a/b-1.2", "", ContainerTar, CompressionXz},
+		{"https://example.com/a/b.git", "git", "a/b", "", "", ""},
+	}
+
+	for _, tc := range tests {
+		got, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", tc.in, err)
+			continue
+		}
+
+		if got.Class != tc.class || got.Name != tc.name || got.InPath != tc.inpath {
+			t.Errorf("\t%40s    want: %s %s %s\tgot:  %s %s %s",
+				tc.in, tc.class, tc.name, tc.inpath, got.Class, got.Name, got.InPath)
+		}
+
+		if got.Class.Container() != tc.container || got.Class.Compression() != tc.compression {
+			t.Errorf("\t%40s    want: %q %q\tgot:  %q %q",
+				tc.in, tc.container, tc.compression, got.Class.Container(), got.Class.Compression())
+		}
+
+		if got.Class.IsArchive() != (tc.container != "") {
+			t.Errorf("\t%40s    IsArchive() = %v", tc.in, got.Class.IsArchive())
+		}
+	}
+}
+
+//nolint:funlen
+func TestVCS(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]testParse{
+		"Subversion": {
+			{
+				"svn://example.com/repo/trunk@1234", map[string]string{
+					"source": "svn://example.com/repo/trunk",
+
+					"class":  "svn",
+					"name":   "repo/trunk",
+					"ref":    "1234",
+					"scheme": "svn",
+				},
+			},
+			{
+				"svn+ssh://user@example.com/repo@HEAD", map[string]string{
+					"source": "svn+ssh://user@example.com/repo",
+
+					"class":    "svn",
+					"ref":      "HEAD",
+					"scheme":   "svn+ssh",
+					"username": "user",
+				},
+			},
+			{
+				"svn+https://example.com/svn/repo@r42", map[string]string{
+					"source": "svn+https://example.com/svn/repo",
+
+					"class":  "svn",
+					"name":   "svn/repo",
+					"ref":    "r42",
+					"scheme": "https",
+				},
+			},
+		},
+		"Mercurial": {
+			{
+				"hg+https://example.com/repo@default", map[string]string{
+					"source": "hg+https://example.com/repo",
+
+					"class":  "hg",
+					"name":   "repo",
+					"ref":    "default",
+					"scheme": "https",
+				},
+			},
+			{
+				"hg+ssh://hg@example.com/repo@a1b2c3d4e5f6", map[string]string{
+					"source": "hg+ssh://hg@example.com/repo",
+
+					"class":  "hg",
+					"ref":    "a1b2c3d4e5f6",
+					"scheme": "ssh",
+				},
+			},
+			{
+				"hg+https://bitbucket.org/user/repo/sub", map[string]string{
+					"source": "hg+https://bitbucket.org/user/repo",
+
+					"class":  "hg",
+					"inpath": "sub",
+					"name":   "user/repo",
+				},
+			},
+		},
+		"Fossil and Bazaar": {
+			{
+				"fossil+https://example.com/repo@trunk", map[string]string{
+					"source": "fossil+https://example.com/repo",
+
+					"class": "fossil",
+					"ref":   "trunk",
+				},
+			},
+			{
+				"bzr+ssh://example.com/repo@revno:3", map[string]string{
+					"source": "bzr+ssh://example.com/repo",
+
+					"class":  "bzr",
+					"ref":    "revno:3",
+					"scheme": "bzr+ssh",
+				},
+			},
+		},
+		"Git": {
+			{
+				"git+ssh://git@example.com:2222/user/repo@main", map[string]string{
+					"source": "ssh://git@example.com:2222/user/repo.git",
+
+					"class":  "git",
+					"ref":    "main",
+					"scheme": "ssh",
+				},
+			},
+		},
+	}
+
+	for name, ts := range tests {
+		ts := ts // https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, tc := range ts {
+				got, err := Parse(tc.in)
+
+				if err != nil {
+					t.Errorf("Parse(%q) = unexpected err %q", tc.in, err)
+					continue
+				}
+
+				m, _ := got.Map()
+
+				for ke, ve := range tc.out {
+					if va, ok := m[ke]; ok {
+						if ve != va {
+							t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidRef(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{
+		"svn://example.com/repo@trunk",
+		"hg+https://example.com/repo@a:b",
+		"bzr://example.com/repo@Tag",
+		"https://example.com/a/b.tar.gz@v1",
+		"https://example.com/a/b@v1",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = expected error", in)
+		}
+	}
+}
+
+func TestVCSRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{
+		"hg+https://example.com/repo@default",
+		"hg+ssh://hg@example.com/repo",
+		"svn+https://example.com/svn/repo@r42",
+		"svn+ssh://example.com/repo@42",
+		"fossil+https://example.com/repo@trunk",
+		"bzr+https://example.com/repo",
+	} {
+		us, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", in, err)
+			continue
+		}
+
+		got, err := Parse(us.canonical())
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", us.canonical(), err)
+			continue
+		}
+
+		if got.Class != us.Class || got.Source != us.Source || got.ID != us.ID {
+			t.Errorf("\t%40s    want: %s %s %s\tgot:  %s %s %s",
+				in, us.Class, us.Source, us.ID, got.Class, got.Source, got.ID)
+		}
+	}
+}
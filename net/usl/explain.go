@@ -0,0 +1,63 @@
+package usl
+
+import (
+	"fmt"
+)
+
+// Step is a decision made while parsing a USL.
+type Step struct {
+	Rule    string // Name of the rule, e.g. "matchSSH"
+	Matched bool   // Whether the rule applied
+	Detail  string // Human readable description of the decision
+}
+
+func (s Step) String() string {
+	mark := "-"
+	if s.Matched {
+		mark = "+"
+	}
+
+	return fmt.Sprintf("%s %s: %s", mark, s.Rule, s.Detail)
+}
+
+// ParseExplain parses the given input like Parse and returns an ordered trace of the decisions made, which is also
+// returned on errors up to the failing step.
+func ParseExplain(rawurl string) (*USL, []Step, error) {
+	tr := &tracer{}
+
+	us, err := parseLenient(rawurl, tr)
+	if err == nil {
+		err = us.validate()
+		tr.add("validate", err == nil && us.Ref != "", "%s", validation(us, err))
+	}
+
+	if err != nil {
+		return nil, tr.steps, err
+	}
+
+	return us, tr.steps, nil
+}
+
+// tracer records the steps of parsing; a nil tracer records nothing.
+type tracer struct {
+	steps []Step
+}
+
+func (tr *tracer) add(rule string, matched bool, format string, args ...interface{}) {
+	if tr == nil {
+		return
+	}
+
+	tr.steps = append(tr.steps, Step{rule, matched, fmt.Sprintf(format, args...)})
+}
+
+func validation(us *USL, err error) string {
+	switch {
+	case err != nil:
+		return err.Error()
+	case us.Ref == "":
+		return "no reference to validate"
+	}
+
+	return fmt.Sprintf("reference %q is valid for class %q", us.Ref, us.Class)
+}
@@ -0,0 +1,75 @@
+package usl
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testExplain struct {
+	in      string
+	matched []string
+	err     bool
+}
+
+func TestParseExplain(t *testing.T) {
+	t.Parallel()
+
+	tests := []testExplain{
+		{"foo:bar", []string{"matchSSH"}, false},
+		{"git@github.com:user/repo", []string{"matchSpecial", "provider", "provider"}, false},
+		{"github.com/user/repo.git@main", []string{"matchSpecial", "parseRef", "parseClass", "validate"}, false},
+		{"example.com/a/b.zip/c", []string{"fallback", "normalize", "parseClass"}, false},
+		{"HTTPS://Example.COM/a.tar.gz", []string{"scheme", "normalize", "parseClass"}, false},
+		{"hg+https://example.com/repo@default", []string{"scheme", "normalize", "vcsScheme", "parseRef", "validate"}, false},
+		{"ghcr.io/owner/image:1.0", []string{"matchOCI", "oci", "validate"}, false},
+		{"s3://bucket/key", []string{"scheme", "normalize", "objectStorage"}, false},
+		{"github.com/user/repo@a..b", []string{"matchSpecial", "parseRef", "provider", "provider"}, true},
+		{"./local", []string{"local"}, true},
+	}
+
+	for _, tc := range tests {
+		us, steps, err := ParseExplain(tc.in)
+
+		if (err != nil) != tc.err {
+			t.Errorf("ParseExplain(%q) = unexpected err %v", tc.in, err)
+			continue
+		}
+
+		if !tc.err && us == nil {
+			t.Errorf("ParseExplain(%q) = nil USL", tc.in)
+			continue
+		}
+
+		var matched []string
+
+		for _, step := range steps {
+			if step.Matched {
+				matched = append(matched, step.Rule)
+			}
+		}
+
+		if !reflect.DeepEqual(matched, tc.matched) {
+			t.Errorf("ParseExplain(%q) = matched %q, want %q", tc.in, matched, tc.matched)
+		}
+	}
+}
+
+func TestParseExplainAgrees(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{"foo:bar", "github.com/user/repo/sub@v1", "https://[::1]:443/a.git", "oci://ghcr.io/a/b"} {
+		want, err := Parse(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, _, err := ParseExplain(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseExplain(%q) = %+v, want %+v", in, got, want)
+		}
+	}
+}
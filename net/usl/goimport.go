@@ -0,0 +1,259 @@
+package usl
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const goImportTimeout = 30 * time.Second
+
+// Version control systems understood in go-import meta tags along with the corresponding classes
+var goImportClasses = map[string]Class{
+	"bzr":    "bzr",
+	"fossil": "fossil",
+	"git":    "git",
+	"hg":     "hg",
+	"svn":    "svn",
+}
+
+// Static mappings of well known Go import path hosts which could be resolved without a network round trip
+var goImportStatics = []struct {
+	re     *regexp.Regexp
+	repo   string
+	prefix string
+	ref    string
+}{
+	{
+		re:     regexp.MustCompile(`^(?P<host>github\.com|gitlab\.com|bitbucket\.org)/(?P<user>[^/]+)/(?P<repo>[^/]+)`),
+		repo:   "https://${host}/${user}/${repo}.git",
+		prefix: "${host}/${user}/${repo}",
+	},
+	{
+		re:     regexp.MustCompile(`^golang\.org/x/(?P<repo>[^/]+)`),
+		repo:   "https://go.googlesource.com/${repo}.git",
+		prefix: "golang.org/x/${repo}",
+	},
+	{
+		re:     regexp.MustCompile(`^gopkg\.in/(?P<repo>[^/.]+)\.(?P<version>v[0-9]+)`),
+		repo:   "https://github.com/go-${repo}/${repo}.git",
+		prefix: "gopkg.in/${repo}.${version}",
+		ref:    "${version}",
+	},
+	{
+		re:     regexp.MustCompile(`^gopkg\.in/(?P<user>[^/]+)/(?P<repo>[^/.]+)\.(?P<version>v[0-9]+)`),
+		repo:   "https://github.com/${user}/${repo}.git",
+		prefix: "gopkg.in/${user}/${repo}.${version}",
+		ref:    "${version}",
+	},
+}
+
+// GoResolver resolves Go import paths, including vanity ones, to the USLs of the repositories holding the code.
+type GoResolver struct {
+	Client   *http.Client // HTTP client to fetch go-import meta tags, a client with a timeout is used if nil
+	Insecure bool         // Fetch go-import meta tags over plain http instead of https
+	NoStatic bool         // Always fetch go-import meta tags even for well known hosts
+}
+
+// ResolveGoImport resolves the given Go import path by using a default GoResolver.
+func ResolveGoImport(importPath string) (*USL, error) {
+	return (&GoResolver{}).Resolve(importPath)
+}
+
+// Resolve returns the repository USL of the given Go import path by following the "?go-get=1" convention.  InPath
+// is set to the package directory inside the repository.  An optional "@version" suffix is kept as the reference.
+func (r *GoResolver) Resolve(importPath string) (*USL, error) {
+	importPath, ref, _ := parseRef(strings.Trim(importPath, "/"))
+	if importPath == "" {
+		return nil, fmt.Errorf("empty import path")
+	}
+
+	if !r.NoStatic {
+		if repo, prefix, staticRef, ok := resolveGoImportStatic(importPath); ok {
+			if ref == "" {
+				ref = staticRef
+			}
+
+			return goImportUSL(repo, strings.TrimPrefix(importPath, prefix), ref)
+		}
+	}
+
+	imports, err := r.fetch(importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	imp, ok := matchGoImport(imports, importPath)
+	if !ok {
+		return nil, fmt.Errorf("no go-import meta tag found for %q", importPath)
+	}
+
+	class, ok := goImportClasses[imp.vcs]
+	if !ok {
+		return nil, fmt.Errorf("unsupported version control system %q for %q", imp.vcs, importPath)
+	}
+
+	repo := imp.repo
+
+	switch {
+	case class == "git":
+		if !strings.HasSuffix(repo, ".git") {
+			repo += ".git"
+		}
+	case !strings.HasPrefix(repo, class.String()+":") && !strings.HasPrefix(repo, class.String()+"+"):
+		repo = class.String() + "+" + repo
+	}
+
+	return goImportUSL(repo, strings.TrimPrefix(importPath, imp.prefix), ref)
+}
+
+func (r *GoResolver) fetch(importPath string) ([]goImport, error) {
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: goImportTimeout}
+	}
+
+	scheme := "https"
+	if r.Insecure {
+		scheme = "http"
+	}
+
+	resp, err := client.Get(scheme + "://" + importPath + "?go-get=1")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching go-import meta tags for %q: %s", importPath, resp.Status)
+	}
+
+	return parseGoImports(resp.Body)
+}
+
+func goImportUSL(repo, subdir, ref string) (*USL, error) {
+	in := repo
+
+	if ref != "" {
+		in += "@" + ref
+	}
+
+	us, err := Parse(in)
+	if err != nil {
+		return nil, err
+	}
+
+	us.InPath = strings.Trim(subdir, "/")
+
+	return us, nil
+}
+
+func resolveGoImportStatic(importPath string) (string, string, string, bool) {
+	for _, static := range goImportStatics {
+		match := static.re.FindStringSubmatchIndex(importPath)
+		if match == nil {
+			continue
+		}
+
+		expand := func(template string) string {
+			return string(static.re.ExpandString(nil, template, importPath, match))
+		}
+
+		prefix := expand(static.prefix)
+		if len(importPath) > len(prefix) && importPath[len(prefix)] != '/' {
+			continue
+		}
+
+		return expand(static.repo), prefix, expand(static.ref), true
+	}
+
+	return "", "", "", false
+}
+
+type goImport struct {
+	prefix, vcs, repo string
+}
+
+func matchGoImport(imports []goImport, importPath string) (goImport, bool) {
+	var (
+		found goImport
+		ok    bool
+	)
+
+	for _, imp := range imports {
+		if imp.vcs == "mod" {
+			continue
+		}
+
+		if importPath != imp.prefix && !strings.HasPrefix(importPath, imp.prefix+"/") {
+			continue
+		}
+
+		if !ok || len(imp.prefix) > len(found.prefix) {
+			found, ok = imp, true
+		}
+	}
+
+	return found, ok
+}
+
+// parseGoImports returns the go-import meta tags in the head of the given HTML document in the same lax way the go
+// command does.
+func parseGoImports(r io.Reader) ([]goImport, error) {
+	var imports []goImport
+
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charsetReader
+	d.Strict = false
+
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if err == io.EOF || len(imports) > 0 {
+				return imports, nil
+			}
+
+			return nil, err
+		}
+
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return imports, nil
+		}
+
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return imports, nil
+		}
+
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") || attrValue(e.Attr, "name") != "go-import" {
+			continue
+		}
+
+		if f := strings.Fields(attrValue(e.Attr, "content")); len(f) == 3 {
+			imports = append(imports, goImport{prefix: f[0], vcs: f[1], repo: f[2]})
+		}
+	}
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "ascii":
+		return input, nil
+	default:
+		return nil, fmt.Errorf("can't decode XML document using charset %q", charset)
+	}
+}
+
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+
+	return ""
+}
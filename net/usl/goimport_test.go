@@ -0,0 +1,206 @@
+package usl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testGoImport struct {
+	in  string
+	out map[string]string
+}
+
+func checkGoImports(t *testing.T, r *GoResolver, tests []testGoImport) {
+	t.Helper()
+
+	for _, tc := range tests {
+		got, err := r.Resolve(tc.in)
+
+		if err != nil {
+			t.Errorf("Resolve(%q) = unexpected err %q", tc.in, err)
+			continue
+		}
+
+		m, _ := got.Map()
+
+		for ke, ve := range tc.out {
+			if va, ok := m[ke]; ok {
+				if ve != va {
+					t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+				}
+			}
+		}
+	}
+}
+
+//nolint:funlen
+func TestResolveGoImportStatic(t *testing.T) {
+	t.Parallel()
+
+	checkGoImports(t, &GoResolver{Client: &http.Client{Transport: failingTransport{}}}, []testGoImport{
+		{
+			"golang.org/x/text", map[string]string{
+				"source": "https://go.googlesource.com/text.git",
+
+				"class":  "git",
+				"inpath": "",
+				"name":   "text",
+			},
+		},
+		{
+			"golang.org/x/text/unicode/norm@v0.3.2", map[string]string{
+				"source": "https://go.googlesource.com/text.git",
+
+				"class":  "git",
+				"inpath": "unicode/norm",
+				"name":   "text",
+				"ref":    "v0.3.2",
+			},
+		},
+		{
+			"github.com/user/repo/sub", map[string]string{
+				"source": "https://github.com/user/repo.git",
+
+				"class":  "git",
+				"inpath": "sub",
+				"name":   "user/repo",
+			},
+		},
+		{
+			"gopkg.in/yaml.v2", map[string]string{
+				"source": "https://github.com/go-yaml/yaml.git",
+
+				"class": "git",
+				"name":  "go-yaml/yaml",
+				"ref":   "v2",
+			},
+		},
+		{
+			"gopkg.in/user/pkg.v3/sub", map[string]string{
+				"source": "https://github.com/user/pkg.git",
+
+				"class":  "git",
+				"inpath": "sub",
+				"name":   "user/pkg",
+				"ref":    "v3",
+			},
+		},
+	})
+}
+
+//nolint:funlen
+func TestResolveGoImportMeta(t *testing.T) {
+	t.Parallel()
+
+	var host string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("go-get") != "1" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/zap"):
+			fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta name="go-import" content="%s/zap mod https://proxy.example.com">
+<meta name="go-import" content="%s/zap git https://github.com/uber-go/zap">
+<meta name="go-source" content="%s/zap https://github.com/uber-go/zap https://github.com/uber-go/zap/tree/master{/dir}">
+</head>
+<body>Nothing to see here</body>
+</html>`, host, host, host)
+		case strings.HasPrefix(r.URL.Path, "/nested"):
+			fmt.Fprintf(w, `<html><head>
+<meta name="go-import" content="%s/nested git https://example.com/outer.git">
+<meta name="go-import" content="%s/nested/inner git https://example.com/inner.git">
+</head></html>`, host, host)
+		case strings.HasPrefix(r.URL.Path, "/hg"):
+			fmt.Fprintf(w, `<meta name="go-import" content="%s/hg hg https://example.com/hg">`, host)
+		case strings.HasPrefix(r.URL.Path, "/svn"):
+			fmt.Fprintf(w, `<meta name="go-import" content="%s/svn svn svn://example.com/svn">`, host)
+		case strings.HasPrefix(r.URL.Path, "/cvs"):
+			fmt.Fprintf(w, `<meta name="go-import" content="%s/cvs cvs https://example.com/cvs">`, host)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	host = strings.TrimPrefix(server.URL, "http://")
+	r := &GoResolver{Client: server.Client(), Insecure: true}
+
+	checkGoImports(t, r, []testGoImport{
+		{
+			host + "/zap", map[string]string{
+				"source": "https://github.com/uber-go/zap.git",
+
+				"class":  "git",
+				"inpath": "",
+				"name":   "uber-go/zap",
+			},
+		},
+		{
+			host + "/zap/zapcore@v1.21.0", map[string]string{
+				"source": "https://github.com/uber-go/zap.git",
+
+				"class":  "git",
+				"inpath": "zapcore",
+				"name":   "uber-go/zap",
+				"ref":    "v1.21.0",
+			},
+		},
+		{
+			host + "/nested/inner/pkg", map[string]string{
+				"source": "https://example.com/inner.git",
+
+				"class":  "git",
+				"inpath": "pkg",
+				"name":   "inner",
+			},
+		},
+		{
+			host + "/nested/innerx", map[string]string{
+				"source": "https://example.com/outer.git",
+
+				"class":  "git",
+				"inpath": "innerx",
+				"name":   "outer",
+			},
+		},
+		{
+			host + "/hg/sub", map[string]string{
+				"source": "hg+https://example.com/hg",
+
+				"class":  "hg",
+				"inpath": "sub",
+				"name":   "hg",
+			},
+		},
+		{
+			host + "/svn", map[string]string{
+				"source": "svn://example.com/svn",
+
+				"class":  "svn",
+				"scheme": "svn",
+			},
+		},
+	})
+
+	for _, in := range []string{host + "/cvs", host + "/missing", host + "/nested2"} {
+		if _, err := r.Resolve(in); err == nil {
+			t.Errorf("Resolve(%q) = expected error", in)
+		}
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("unexpected request to %s", r.URL)
+}
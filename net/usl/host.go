@@ -0,0 +1,180 @@
+package usl
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Profile used to validate host names and to convert internationalized domain names to the canonical ASCII form.
+// Unlike the lookup profile, underscores are allowed since they are common in host aliases.
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.StrictDomainName(false),
+	idna.BidiRule(),
+	idna.VerifyDNSLength(true),
+)
+
+// Default ports of schemes which are dropped during normalization
+var defaultPorts = map[string]string{
+	"bzr+ssh": "22",
+	"ftp":     "21",
+	"git":     "9418",
+	"http":    "80",
+	"https":   "443",
+	"ssh":     "22",
+	"svn":     "3690",
+	"svn+ssh": "22",
+}
+
+// IPv6 literal with an optional zone identifier in brackets
+const ipv6Pattern = `\[[0-9a-fA-F:.]+(?:%[^\]]+)?\]`
+
+var reZone = regexp.MustCompile(`\[([0-9a-fA-F:.]+)%([^\]]+)\]`)
+
+// NormalizeHost returns the canonical form of the given host name, i.e. the lower cased ASCII (punycode) form
+// without a trailing dot.  IPv6 literals, which could be given in brackets and with a zone identifier, are returned
+// in the canonical textual form without brackets.
+func NormalizeHost(host string) (string, error) {
+	if host == "" {
+		return host, nil
+	}
+
+	if ip, ok := normalizeIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")); ok {
+		return ip, nil
+	}
+
+	ascii, err := hostProfile.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid host name %q: %v", host, err)
+	}
+
+	return ascii, nil
+}
+
+// UnicodeHost returns the Unicode form of the given host name for display purposes.
+func UnicodeHost(host string) string {
+	if u, err := hostProfile.ToUnicode(host); err == nil {
+		return u
+	}
+
+	return host
+}
+
+// Unicode returns a copy of the USL where the host name is displayed in Unicode form.  The ID is left in the
+// canonical ASCII form to keep it stable.
+func (us *USL) Unicode() *USL {
+	n := *us
+
+	domain := UnicodeHost(us.Domain)
+	if domain == us.Domain {
+		return &n
+	}
+
+	n.Domain = domain
+	n.Host = joinHostPort(domain, us.Port)
+	n.Source = strings.Replace(us.Source, us.Host, n.Host, 1)
+
+	return &n
+}
+
+// normalizeHost normalizes the host of the USL; see NormalizeHost.
+func (us *USL) normalizeHost() error {
+	if isObjectStorage(us.Scheme) {
+		return nil
+	}
+
+	domain, err := NormalizeHost(us.Domain)
+	if err != nil {
+		return err
+	}
+
+	_, transport, _ := vcsScheme(us.Scheme)
+	if us.Port == defaultPorts[transport] {
+		us.Port = ""
+	}
+
+	us.Domain = domain
+	us.Host = joinHostPort(domain, us.Port)
+
+	return nil
+}
+
+// urlHost returns the host in the form suitable for URLs where the zone identifier of IPv6 literals is escaped.
+func (us *USL) urlHost() string {
+	return strings.Replace(us.Host, "%", "%25", 1)
+}
+
+// splitZone removes the zone identifier of the IPv6 literal in the given URL and returns it unescaped, e.g.
+// "[fe80::1%eth0]" and "[fe80::1%25eth0]" are both turned into "[fe80::1]" with the zone "eth0".
+func splitZone(in string) (string, string) {
+	m := reZone.FindStringSubmatchIndex(in)
+	if m == nil {
+		return in, ""
+	}
+
+	zone := strings.TrimPrefix(in[m[4]:m[5]], "25")
+	if unescaped, err := url.PathUnescape(zone); err == nil {
+		zone = unescaped
+	}
+
+	return in[:m[3]] + in[m[5]:], zone
+}
+
+// normalizeIP returns the canonical textual form of the given IP address which may have an IPv6 zone identifier.
+func normalizeIP(host string) (string, bool) {
+	addr, zone := cut(host, "%")
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", false
+	}
+
+	if zone != "" {
+		return ip.String() + "%" + zone, true
+	}
+
+	return ip.String(), true
+}
+
+// normalizeLeadingHost normalizes the host of a schemeless input in "[user@]host[:/]..." form so that it could be
+// matched against the supported providers.  The input is returned as is if no valid host found.
+func normalizeLeadingHost(in string) string {
+	user, rest := "", in
+	if j := strings.Index(in, "@"); j >= 0 && j < strings.IndexAny(in+"/", "/:[") {
+		user, rest = in[:j+1], in[j+1:]
+	}
+
+	i := strings.IndexAny(rest, "/:")
+	if strings.HasPrefix(rest, "[") {
+		i = strings.Index(rest, "]") + 1
+	}
+
+	if i <= 0 {
+		return in
+	}
+
+	normalized, err := NormalizeHost(rest[:i])
+	if err != nil || normalized == "" {
+		return in
+	}
+
+	return user + joinHostPort(normalized, "") + rest[i:]
+}
+
+// joinHostPort is the inverse of url.URL Hostname and Port, hence IPv6 literals are always put in brackets.
+func joinHostPort(host, port string) string {
+	if port == "" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+
+		return host
+	}
+
+	return net.JoinHostPort(host, port)
+}
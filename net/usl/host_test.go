@@ -0,0 +1,358 @@
+package usl
+
+import (
+	"testing"
+)
+
+//nolint:funlen
+func TestNormalizeHost(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]testParse{
+		"Internationalized": {
+			{
+				"bücher.example/repo", map[string]string{
+					"source": "https://xn--bcher-kva.example/repo",
+
+					"domain": "xn--bcher-kva.example",
+					"id":     `https:%2F%2Fxn--bcher-kva.example%2Frepo`,
+				},
+			},
+			{
+				"xn--bcher-kva.example/repo", map[string]string{
+					"source": "https://xn--bcher-kva.example/repo",
+
+					"id": `https:%2F%2Fxn--bcher-kva.example%2Frepo`,
+				},
+			},
+			{
+				"https://BÜCHER.example:8080/repo", map[string]string{
+					"source": "https://xn--bcher-kva.example:8080/repo",
+
+					"host": "xn--bcher-kva.example:8080",
+				},
+			},
+			{
+				"git@bücher.example:user/repo", map[string]string{
+					"source": "git@xn--bcher-kva.example:user/repo",
+				},
+			},
+		},
+		"Mixed case and trailing dot": {
+			{
+				"GitHub.com/user/repo", map[string]string{
+					"source": "https://github.com/user/repo.git",
+
+					"class": "git",
+				},
+			},
+			{
+				"github.com./user/repo", map[string]string{
+					"source": "https://github.com/user/repo.git",
+
+					"class": "git",
+				},
+			},
+			{
+				"GITHUB.COM.:user/repo", map[string]string{
+					"source": "git@github.com:user/repo.git",
+
+					"class": "git",
+				},
+			},
+			{
+				"https://GitLab.com./user/repo", map[string]string{
+					"source": "https://gitlab.com/user/repo.git",
+
+					"class": "git",
+				},
+			},
+			{
+				"ssh://git@Example.COM./a/b", map[string]string{
+					"source": "git@example.com:a/b",
+
+					"domain": "example.com",
+				},
+			},
+		},
+		"IPv6 literals": {
+			{
+				"git@[::1]:repo.git", map[string]string{
+					"source": "git@[::1]:repo.git",
+
+					"class":    "git",
+					"domain":   "::1",
+					"host":     "[::1]",
+					"id":       "git@%5B::1%5D:repo.git",
+					"name":     "repo",
+					"port":     "",
+					"scheme":   "ssh",
+					"username": "git",
+				},
+			},
+			{
+				"[::1]:user/repo.git@main", map[string]string{
+					"source": "[::1]:user/repo.git",
+
+					"host": "[::1]",
+					"name": "user/repo",
+					"ref":  "main",
+				},
+			},
+			{
+				"git@[2001:DB8:0::1]:repo.git", map[string]string{
+					"source": "git@[2001:db8::1]:repo.git",
+
+					"domain": "2001:db8::1",
+				},
+			},
+			{
+				"ssh://git@[::1]:2222/repo.git", map[string]string{
+					"source": "ssh://git@[::1]:2222/repo.git",
+
+					"domain": "::1",
+					"host":   "[::1]:2222",
+					"port":   "2222",
+				},
+			},
+			{
+				"ssh://git@[::1]/repo.git", map[string]string{
+					"source": "git@[::1]:repo.git",
+				},
+			},
+			{
+				"https://[::1]/a/b.git", map[string]string{
+					"source": "https://[::1]/a/b.git",
+
+					"domain": "::1",
+					"host":   "[::1]",
+					"id":     "https:%2F%2F%5B::1%5D%2Fa%2Fb.git",
+					"name":   "a/b",
+				},
+			},
+			{
+				"https://[2001:DB8::1]:8443/a/b.git@v1", map[string]string{
+					"source": "https://[2001:db8::1]:8443/a/b.git",
+
+					"host": "[2001:db8::1]:8443",
+					"port": "8443",
+					"ref":  "v1",
+				},
+			},
+			{
+				"[::1]/a/b.tar.gz", map[string]string{
+					"source": "https://[::1]/a/b.tar.gz",
+
+					"class": "tar.gz",
+				},
+			},
+			{
+				"https://[::ffff:192.0.2.1]/a", map[string]string{
+					"source": "https://192.0.2.1/a",
+
+					"domain": "192.0.2.1",
+				},
+			},
+		},
+		"Zone identifiers": {
+			{
+				"git@[fe80::1%eth0]:repo.git", map[string]string{
+					"source": "git@[fe80::1%eth0]:repo.git",
+
+					"domain": "fe80::1%eth0",
+					"host":   "[fe80::1%eth0]",
+					"id":     "git@%5Bfe80::1%25eth0%5D:repo.git",
+				},
+			},
+			{
+				"ssh://[fe80::1%eth0]:2222/repo.git", map[string]string{
+					"source": "ssh://[fe80::1%25eth0]:2222/repo.git",
+
+					"domain": "fe80::1%eth0",
+					"host":   "[fe80::1%eth0]:2222",
+					"port":   "2222",
+				},
+			},
+			{
+				"ssh://git@[fe80::1%25eth0]/repo.git", map[string]string{
+					"source": "git@[fe80::1%eth0]:repo.git",
+
+					"domain": "fe80::1%eth0",
+				},
+			},
+			{
+				"https://[FE80::1%25en0]/a/b.git", map[string]string{
+					"source": "https://[fe80::1%25en0]/a/b.git",
+
+					"domain": "fe80::1%en0",
+					"host":   "[fe80::1%en0]",
+				},
+			},
+		},
+		"Default ports": {
+			{
+				"ssh://git@example.com:22/a/b.git", map[string]string{
+					"source": "git@example.com:a/b.git",
+
+					"host": "example.com",
+					"port": "",
+				},
+			},
+			{
+				"ssh://git@[::1]:22/a/b.git", map[string]string{
+					"source": "git@[::1]:a/b.git",
+
+					"host": "[::1]",
+					"port": "",
+				},
+			},
+			{
+				"https://example.com:443/a/b.git", map[string]string{
+					"source": "https://example.com/a/b.git",
+
+					"host": "example.com",
+					"port": "",
+				},
+			},
+			{
+				"https://[::1]:443/a/b.git", map[string]string{
+					"source": "https://[::1]/a/b.git",
+
+					"host": "[::1]",
+				},
+			},
+			{
+				"http://192.0.2.1:80/a.git", map[string]string{
+					"source": "http://192.0.2.1/a.git",
+
+					"host": "192.0.2.1",
+				},
+			},
+			{
+				"git://example.com:9418/a.git", map[string]string{
+					"source": "git://example.com/a.git",
+
+					"port": "",
+				},
+			},
+			{
+				"ftp://example.com:21/a.tar.gz", map[string]string{
+					"source": "ftp://example.com/a.tar.gz",
+
+					"port": "",
+				},
+			},
+			{
+				"svn+ssh://example.com:22/r", map[string]string{
+					"source": "svn+ssh://example.com/r",
+
+					"class": "svn",
+					"port":  "",
+				},
+			},
+			{
+				"https://example.com:22/a.git", map[string]string{
+					"source": "https://example.com:22/a.git",
+
+					"port": "22",
+				},
+			},
+			{
+				"ssh://git@example.com:443/a.git", map[string]string{
+					"source": "ssh://git@example.com:443/a.git",
+
+					"port": "443",
+				},
+			},
+		},
+	}
+
+	for name, ts := range tests {
+		ts := ts // https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, tc := range ts {
+				got, err := Parse(tc.in)
+
+				if err != nil {
+					t.Errorf("Parse(%q) = unexpected err %q", tc.in, err)
+					continue
+				}
+
+				m, _ := got.Map()
+
+				for ke, ve := range tc.out {
+					if va, ok := m[ke]; ok {
+						if ve != va {
+							t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultPortEquivalence(t *testing.T) {
+	t.Parallel()
+
+	for with, without := range map[string]string{
+		"ssh://git@example.com:22/user/repo.git": "ssh://git@example.com/user/repo.git",
+		"ssh://github.com:22/user/repo":          "ssh://github.com/user/repo",
+		"https://example.com:443/a.git":          "https://example.com/a.git",
+		"git://example.com:9418/a.git":           "git://example.com/a.git",
+	} {
+		a, err := Parse(with)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", with, err)
+			continue
+		}
+
+		b, err := Parse(without)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", without, err)
+			continue
+		}
+
+		if a.Source != b.Source || a.ID != b.ID {
+			t.Errorf("\t%40s    want: %s %s\tgot:  %s %s", with, b.Source, b.ID, a.Source, a.ID)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	t.Parallel()
+
+	us, err := Parse("https://xn--bcher-kva.example:8080/a.git@main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := us.Unicode()
+
+	if u.Domain != "bücher.example" || u.Host != "bücher.example:8080" ||
+		u.Source != "https://bücher.example:8080/a.git" {
+		t.Errorf("Unicode() = %q %q %q", u.Domain, u.Host, u.Source)
+	}
+
+	if u.ID != us.ID {
+		t.Errorf("Unicode() changed ID from %q to %q", us.ID, u.ID)
+	}
+}
+
+func TestInvalidHost(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{
+		"https://a..b/x",
+		"https://aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa.com/x",
+		"https://-a.com/x",
+		"https://[::1/x",
+		"https://[::g]/x",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = expected error", in)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package usl
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layout is a way of placing the local checkouts of sources in directories under a root directory.
+type Layout string
+
+// Layouts of local checkouts
+const (
+	LayoutGhq    Layout = "ghq"    // ROOT/HOST/NAME, e.g. ~/src/github.com/user/repo
+	LayoutGOPATH Layout = "gopath" // ROOT/src/HOST/NAME, e.g. ~/go/src/github.com/user/repo
+	LayoutFlat   Layout = "flat"   // ROOT/SLUG, e.g. ~/src/github.com-user-repo
+	LayoutID     Layout = "id"     // ROOT/ID where ":" is escaped too, i.e. a directory for each reference
+)
+
+// Separator of the port in host directories, which is the escaped ":" as in the ID layout, e.g. "example.com%3A8080"
+const portSeparator = "%3A"
+
+// Layouts are the supported layouts.
+var Layouts = []Layout{LayoutGhq, LayoutGOPATH, LayoutFlat, LayoutID}
+
+// ParseLayout returns the layout with the given name.
+func ParseLayout(name string) (Layout, error) {
+	for _, l := range Layouts {
+		if string(l) == name {
+			return l, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported layout %q", name)
+}
+
+func (l Layout) String() string {
+	return string(l)
+}
+
+// Path returns the directory of the source under the given root.  Layouts other than the ID layout place sources
+// regardless of their references, and the ghq and GOPATH layouts require sources having domains, which are followed
+// by the ports if any.
+func (l Layout) Path(root string, us *USL) (string, error) {
+	if us.Name == "" {
+		return "", fmt.Errorf("no name to place source %q in %s layout", us.Source, l)
+	}
+
+	switch l {
+	case LayoutGhq, LayoutGOPATH:
+		rel, err := domainPath(us)
+		if err != nil {
+			return "", err
+		}
+
+		if l == LayoutGOPATH {
+			rel = "src/" + rel
+		}
+
+		return filepath.Join(root, filepath.FromSlash(rel)), nil
+	case LayoutFlat:
+		return filepath.Join(root, us.Slug), nil
+	case LayoutID:
+		return filepath.Join(root, strings.ReplaceAll(us.ID, ":", portSeparator)), nil
+	}
+
+	return "", fmt.Errorf("unsupported layout %q", string(l))
+}
+
+// Parse returns the source placed in the given directory under the given root, which is the inverse of Path.  Since
+// the ghq and GOPATH layouts are meant for git checkouts, sources on the supported providers are named after the first
+// two segments after the host, and sources on other hosts are named after the path of the nearest directory having a
+// ".git" entry, which should exist; the rest is the path in repository.  Directories in the flat layout could not be
+// mapped back, as slugs are not reversible.
+func (l Layout) Parse(root, dir string) (*USL, error) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if rel = filepath.ToSlash(rel); rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil, fmt.Errorf("directory %q is not under root %q", dir, root)
+	}
+
+	switch l {
+	case LayoutGhq, LayoutGOPATH:
+		if l == LayoutGOPATH {
+			if root = filepath.Join(root, "src"); !strings.HasPrefix(rel, "src/") {
+				return nil, fmt.Errorf("directory %q is not under %q", dir, root)
+			}
+
+			rel = strings.TrimPrefix(rel, "src/")
+		}
+
+		host, name := cut(rel, "/")
+		if name == "" {
+			return nil, fmt.Errorf("directory %q has no name after host %q", dir, host)
+		}
+
+		if supportedProviders.contains(host) {
+			return Parse(escapeAt(rel))
+		}
+
+		name, inpath, err := repositoryOf(filepath.Join(root, host), name)
+		if err != nil {
+			return nil, err
+		}
+
+		domain, port := cut(host, portSeparator)
+
+		return Parse("https://" + joinHostPort(domain, port) + "/" + escapeAt(name) + ".git" + inpath)
+	case LayoutFlat:
+		return nil, fmt.Errorf("directories in %s layout could not be mapped back to sources", l)
+	case LayoutID:
+		if strings.Contains(rel, "/") {
+			return nil, fmt.Errorf("directory %q is not directly under root %q", dir, root)
+		}
+
+		id, err := url.PathUnescape(rel)
+		if err != nil {
+			return nil, err
+		}
+
+		return Parse(id)
+	}
+
+	return nil, fmt.Errorf("unsupported layout %q", string(l))
+}
+
+// repositoryOf splits the given slash separated path under the given host directory into the name of the repository,
+// i.e. the path of the nearest directory having a ".git" entry, and the path in repository with a leading slash.
+func repositoryOf(hostDir, path string) (string, string, error) {
+	segments := strings.Split(path, "/")
+
+	for i := len(segments); i > 0; i-- {
+		name := strings.Join(segments[:i], "/")
+
+		if _, err := os.Stat(filepath.Join(hostDir, filepath.FromSlash(name), ".git")); err == nil {
+			if i == len(segments) {
+				return name, "", nil
+			}
+
+			return name, "/" + escapeAt(strings.Join(segments[i:], "/")), nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no repository having a .git entry found along %q under %q", path, hostDir)
+}
+
+// domainPath returns the slash separated HOST/NAME path of the source where HOST is the domain followed by the port.
+func domainPath(us *USL) (string, error) {
+	if us.Domain == "" || isObjectStorage(us.Scheme) || us.Scheme == "file" {
+		return "", fmt.Errorf("no domain to place source %q under", us.Source)
+	}
+
+	if strings.ContainsAny(us.Domain, `:%\`) {
+		return "", fmt.Errorf("domain %q could not be used as a directory name", us.Domain)
+	}
+
+	for _, segment := range strings.Split(us.Name, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.Contains(segment, `\`) {
+			return "", fmt.Errorf("name %q could not be used as a directory path", us.Name)
+		}
+	}
+
+	host := us.Domain
+	if us.Port != "" {
+		host += portSeparator + us.Port
+	}
+
+	return host + "/" + us.Name, nil
+}
@@ -0,0 +1,164 @@
+package usl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testLayout struct {
+	in     string
+	layout Layout
+	path   string
+	back   string
+}
+
+func TestLayout(t *testing.T) {
+	t.Parallel()
+
+	root, err := ioutil.TempDir("", "layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(root)
+
+	tests := []testLayout{
+		{"github.com/user/repo@main", LayoutGhq, "github.com/user/repo", "https://github.com/user/repo.git"},
+		{"github.com/user/repo@main", LayoutGOPATH, "src/github.com/user/repo", "https://github.com/user/repo.git"},
+		{"github.com/User/Repo", LayoutFlat, "github.com-user-repo-46b320e5", ""},
+		{"github.com/user/repo@main", LayoutID, "https%3A%2F%2Fgithub.com%2Fuser%2Frepo.git@main",
+			"https://github.com/user/repo.git"},
+		{"git@example.com:group/sub/repo.git", LayoutGhq, "example.com/group/sub/repo",
+			"https://example.com/group/sub/repo.git"},
+		{"https://example.com/a/b.git@v1", LayoutID, "https%3A%2F%2Fexample.com%2Fa%2Fb.git@v1",
+			"https://example.com/a/b.git"},
+		{"https://example.com:8080/a/b.git", LayoutGhq, "example.com%3A8080/a/b", "https://example.com:8080/a/b.git"},
+		{"ssh://git@example.com:2222/a/b.git", LayoutGOPATH, "src/example.com%3A2222/a/b",
+			"https://example.com:2222/a/b.git"},
+	}
+
+	for _, tc := range tests {
+		us, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", tc.in, err)
+			continue
+		}
+
+		want := filepath.Join(root, filepath.FromSlash(tc.path))
+
+		got, err := tc.layout.Path(root, us)
+		if err != nil || got != want {
+			t.Errorf("%s.Path(%q) = %q, %v, want %q", tc.layout, tc.in, got, err, want)
+			continue
+		}
+
+		// Checkouts in the ghq and GOPATH layouts are found by their .git entries
+		if err := os.MkdirAll(filepath.Join(got, ".git"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		back, err := tc.layout.Parse(root, got)
+
+		switch {
+		case tc.back == "":
+			if err == nil {
+				t.Errorf("%s.Parse(%q) = %q, want error", tc.layout, got, back.Source)
+			}
+		case err != nil:
+			t.Errorf("%s.Parse(%q) = unexpected err %q", tc.layout, got, err)
+		case back.Source != tc.back || tc.layout == LayoutID && back.Ref != us.Ref:
+			t.Errorf("%s.Parse(%q) = %q@%q, want %q", tc.layout, got, back.Source, back.Ref, tc.back)
+		}
+	}
+}
+
+func TestLayoutParse(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.FromSlash("/src")
+
+	us, err := LayoutGhq.Parse(root, filepath.Join(root, "github.com", "user", "repo", "sub", "dir"))
+	if err != nil || us.Name != "user/repo" || us.InPath != "sub/dir" {
+		t.Errorf("Parse() = %+v, %v, want name and path in repository", us, err)
+	}
+
+	// Directory names are taken literally rather than as references
+	us, err = LayoutGhq.Parse(root, filepath.Join(root, "github.com", "user", "repo", "a@b", "50%"))
+	if err != nil || us.Name != "user/repo" || us.InPath != "a@b/50%" || us.Ref != "" {
+		t.Errorf("Parse() = %+v, %v, want '@' and '%%' in path in repository", us, err)
+	}
+
+	tmp, err := ioutil.TempDir("", "layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmp)
+
+	if err := os.MkdirAll(filepath.Join(tmp, "example.com", "group", "repo", "sub", "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Repositories on hosts other than the providers are told from the paths in them by their .git entries
+	us, err = LayoutGhq.Parse(tmp, filepath.Join(tmp, "example.com", "group", "repo", "sub"))
+	if err == nil {
+		t.Errorf("Parse() = %q, want error for a path without a repository", us.Source)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(tmp, "example.com", "group", "repo", ".git"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	us, err = LayoutGhq.Parse(tmp, filepath.Join(tmp, "example.com", "group", "repo", "sub", "dir"))
+	if err != nil || us.Name != "group/repo" || us.InPath != "sub/dir" {
+		t.Errorf("Parse() = %+v, %v, want name and path in repository", us, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(tmp, "example.com", "a@b", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	us, err = LayoutGhq.Parse(tmp, filepath.Join(tmp, "example.com", "a@b", "c@d"))
+	if err != nil || us.Name != "a@b" || us.InPath != "c@d" || us.Ref != "" {
+		t.Errorf("Parse() = %+v, %v, want '@' in name and path in repository", us, err)
+	}
+
+	for _, dir := range []string{
+		"/src", "/elsewhere/github.com/user/repo", "/src/github.com", "/src/src", "/src/github.com/user/repo",
+	} {
+		layout := LayoutGhq
+		if dir == "/src/src" || dir == "/src/github.com/user/repo" {
+			layout = LayoutGOPATH
+		}
+
+		if us, err := layout.Parse(root, filepath.FromSlash(dir)); err == nil {
+			t.Errorf("%s.Parse(%q) = %q, want error", layout, dir, us.Source)
+		}
+	}
+}
+
+func TestLayoutInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{"s3://bucket/key.tar.gz", "file:///tmp/repo.git", "ssh://git@[::1]/a/b.git"} {
+		us, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", in, err)
+			continue
+		}
+
+		if got, err := LayoutGhq.Path("/src", us); err == nil {
+			t.Errorf("Path(%q) = %q, want error", in, got)
+		}
+	}
+
+	if _, err := ParseLayout("nope"); err == nil {
+		t.Errorf("ParseLayout(%q) = expected error", "nope")
+	}
+
+	if _, err := Layout("nope").Path("/src", &USL{Name: "x"}); err == nil {
+		t.Errorf("Path() = expected error for unsupported layout")
+	}
+}
@@ -0,0 +1,179 @@
+package usl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Severity is the severity of a diagnostic.
+type Severity int
+
+// Severities in increasing order.
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Diagnostic codes
+const (
+	CodeInvalid      = "invalid"
+	CodeImplicit     = "implicit-scheme"
+	CodeAmbiguous    = "ambiguous-scp"
+	CodeEmptyName    = "empty-name"
+	CodeRefFormat    = "ref-format"
+	CodeInvalidRef   = "invalid-ref"
+	CodeTraversal    = "path-traversal"
+	CodeCredentials  = "credentials"
+	CodeUsername     = "username"
+	CodeNonCanonical = "non-canonical"
+)
+
+// Diagnostic describes a problem found in a USL along with a suggested fix, which is empty if none available.
+type Diagnostic struct {
+	Severity   Severity
+	Code       string
+	Message    string
+	Suggestion string
+}
+
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%s: %s [%s]", d.Severity, d.Message, d.Code)
+	if d.Suggestion != "" {
+		s += fmt.Sprintf(" (suggestion: %s)", d.Suggestion)
+	}
+
+	return s
+}
+
+// Diagnostics is a list of diagnostics.
+type Diagnostics []Diagnostic
+
+// HasErrors reports whether any of the diagnostics is an error.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity >= SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	reTraversal = regexp.MustCompile(`(^|[/:\\])\.\.([/\\@]|$)`)
+	reHostAlias = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// Lint parses the given input strictly and reports every problem found instead of silently accepting it, i.e.
+// implicit schemes, ambiguous scp-like syntax, invalid git references, path traversals, credentials and
+// non-canonical spellings.  The parsed USL is nil if the input could not be parsed at all.
+func Lint(rawurl string) (*USL, Diagnostics) {
+	var ds Diagnostics
+
+	report := func(severity Severity, code, suggestion, format string, args ...interface{}) {
+		ds = append(ds, Diagnostic{severity, code, fmt.Sprintf(format, args...), suggestion})
+	}
+
+	if reTraversal.MatchString(rawurl) {
+		report(SeverityError, CodeTraversal, "", "path traversal with '..' in %q", rawurl)
+	}
+
+	us, err := parseLenient(rawurl, nil)
+	if err != nil {
+		report(SeverityError, CodeInvalid, "", "%v", err)
+
+		return nil, ds
+	}
+
+	canonical := us.canonical()
+
+	if !strings.Contains(rawurl, "://") {
+		lintSchemeless(us, rawurl, canonical, report)
+	}
+
+	if us.Name == "" && us.Scheme != ociScheme && !isObjectStorage(us.Scheme) {
+		report(SeverityWarning, CodeEmptyName, "", "no repository or file name found in %q", rawurl)
+	}
+
+	if err := us.validate(); err != nil && us.Class == "git" {
+		report(SeverityError, CodeRefFormat, "", "%v", err)
+	} else if err != nil {
+		report(SeverityError, CodeInvalidRef, "", "%v", err)
+	}
+
+	if us.Password != "" {
+		report(SeverityError, CodeCredentials, us.withCredentials(us.Username, ""),
+			"password found in %q, use a credential helper instead", rawurl)
+	} else if us.Username != "" && strings.HasPrefix(us.Scheme, "http") {
+		report(SeverityWarning, CodeUsername, us.withCredentials("", ""),
+			"user name %q found, which may be an access token", us.Username)
+	}
+
+	if rawurl != canonical {
+		report(SeverityInfo, CodeNonCanonical, canonical, "non-canonical spelling %q", rawurl)
+	}
+
+	return us, ds
+}
+
+// lintSchemeless reports the problems specific to inputs without an explicit scheme.
+func lintSchemeless(us *USL, rawurl, canonical string, report func(Severity, string, string, string, ...interface{})) {
+	if us.Scheme == "ssh" {
+		host, path := cut(rawurl, ":")
+
+		if !strings.Contains(host, "@") && reHostAlias.MatchString(host) && host != "localhost" {
+			report(SeverityWarning, CodeAmbiguous, "ssh://"+host+"/"+strings.TrimPrefix(path, "/"),
+				"%q could be a local path or a host alias %q in scp-like syntax", rawurl, host)
+		}
+
+		return
+	}
+
+	if us.Scheme == fallbackScheme && !supportedProviders.contains(us.Host) {
+		report(SeverityWarning, CodeImplicit, canonical, "no scheme found in %q, assuming %s", rawurl, fallbackScheme)
+	}
+}
+
+// canonical returns the canonical spelling of the USL including the in repository path and the reference.
+func (us *USL) canonical() string {
+	if us.Class == ociScheme {
+		return ociScheme + "://" + us.Reference()
+	}
+
+	s := us.Source
+
+	if us.InPath != "" {
+		s += "/" + escapeAt(us.InPath)
+	}
+
+	if us.Ref != "" {
+		s += "@" + escapeAt(us.Ref)
+	}
+
+	return s
+}
+
+// withCredentials returns the canonical spelling of the USL with the given credentials.
+func (us *USL) withCredentials(username, password string) string {
+	n := *us
+
+	n.Username, n.Password = username, password
+	n.Source = n.source()
+
+	return n.canonical()
+}
@@ -0,0 +1,247 @@
+package usl
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// LocalRules describe how local paths are recognized, turned into file URLs and inspected on a platform.  Rules of
+// a foreign platform could be used to process its paths, e.g. Windows paths on Linux.
+type LocalRules struct {
+	Windows  bool                   // Recognize drive letters, backslash separators and UNC paths
+	Home     func() (string, error) // Home directory used to expand "~"
+	Getwd    func() (string, error) // Working directory used to resolve relative paths
+	Exists   func(string) bool      // Used to recognize bare relative names, e.g. "repo.git"; nil to disable
+	Git      bool                   // Inspect git working trees to fill in the class, reference and paths
+	Upstream bool                   // Fill in the upstream remote while inspecting git working trees
+}
+
+// DefaultLocalRules are the local path rules of the running platform.
+var DefaultLocalRules = NewLocalRules(runtime.GOOS)
+
+// NewLocalRules returns the local path rules for the given platform which use the running process environment.
+func NewLocalRules(goos string) *LocalRules {
+	return &LocalRules{
+		Windows: goos == "windows",
+		Home:    os.UserHomeDir,
+		Getwd:   os.Getwd,
+		Exists: func(name string) bool {
+			_, err := os.Stat(name)
+
+			return err == nil
+		},
+	}
+}
+
+var (
+	reDrive     = regexp.MustCompile(`^[a-zA-Z]:([\\/]|$)`)
+	reDriveHost = regexp.MustCompile(`^[a-zA-Z]:$`)
+)
+
+// IsLocal reports whether the given input is syntactically a local path, i.e. an absolute path, an explicitly
+// relative path or a path relative to the home directory.
+func (r *LocalRules) IsLocal(in string) bool {
+	switch in {
+	case ".", "..", "~":
+		return true
+	}
+
+	prefixes := []string{"/", "./", "../", "~/"}
+	if r.Windows {
+		prefixes = append(prefixes, `\`, `.\`, `..\`, `~\`)
+
+		if reDrive.MatchString(in) {
+			return true
+		}
+	}
+
+	for _, p := range prefixes {
+		if strings.HasPrefix(in, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseMayLocalPath parses the given input as a USL after turning it into a file URL if it is a local path.  Bare
+// relative names are considered local paths if they exist and don't look like a domain, e.g. "repo" or "repo.git".
+// A reference could follow the last '@' in the last segment of local paths, e.g. "./repo.git@main", while the other
+// '@' and '%' characters are taken literally.  When Git is set, local paths inside git working trees are described as
+// git sources; see inspectWorkTree.
+func (r *LocalRules) ParseMayLocalPath(rawurl string) (*USL, error) {
+	in, local, err := r.localURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	us, err := Parse(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if local && r.Git {
+		if err := r.inspectWorkTree(us); err != nil {
+			return nil, err
+		}
+	}
+
+	return us, nil
+}
+
+// ParseExplainMayLocalPath is the version of ParseExplain accepting local paths like ParseMayLocalPath.
+func (r *LocalRules) ParseExplainMayLocalPath(rawurl string) (*USL, []Step, error) {
+	in, local, err := r.localURL(rawurl)
+	if err != nil {
+		return nil, []Step{{"localPath", true, err.Error()}}, err
+	}
+
+	if !local {
+		return ParseExplain(in)
+	}
+
+	steps := []Step{{"localPath", true, fmt.Sprintf("local path turned into %q", in)}}
+
+	us, more, err := ParseExplain(in)
+	if steps = append(steps, more...); err != nil {
+		return nil, steps, err
+	}
+
+	if r.Git {
+		if err := r.inspectWorkTree(us); err != nil {
+			return nil, append(steps, Step{"inspectWorkTree", false, err.Error()}), err
+		}
+
+		if us.Class != "git" {
+			steps = append(steps, Step{"inspectWorkTree", false, "no git working tree found"})
+		} else {
+			steps = append(steps, Step{"inspectWorkTree", true, fmt.Sprintf("git working tree at %q", us.Source)})
+		}
+	}
+
+	return us, steps, nil
+}
+
+// localURL returns the file URL of the given input along with true if it is a local path, otherwise the input as is.
+func (r *LocalRules) localURL(rawurl string) (string, bool, error) {
+	if !r.IsLocal(rawurl) && !r.isBareLocal(rawurl) {
+		return rawurl, false, nil
+	}
+
+	p, ref := rawurl, ""
+	if i := strings.LastIndex(rawurl, "@"); i >= 0 && !strings.ContainsAny(rawurl[i:], r.separators()) {
+		p, ref = rawurl[:i], rawurl[i+1:]
+	}
+
+	in, err := r.FileURL(p)
+	if err != nil {
+		return "", true, err
+	}
+
+	if ref != "" {
+		in += "@" + escapeAt(ref)
+	}
+
+	return in, true, nil
+}
+
+// FileURL returns the file URL of the given local path with the special characters percent encoded.  Like in USLs, a
+// literal '@' in the path is written as "%40" since the last '@' separates the reference, e.g. "/a@b" is turned into
+// "file:///a%40b".
+func (r *LocalRules) FileURL(in string) (string, error) {
+	p := r.slash(in)
+
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, err := r.Home()
+		if err != nil {
+			return "", err
+		}
+
+		p = r.slash(home) + p[1:]
+	}
+
+	host := ""
+
+	switch {
+	case r.Windows && strings.HasPrefix(p, "//"):
+		host, p = cut(p[2:], "/")
+		p = "/" + p
+	case !r.isAbs(p):
+		wd, err := r.Getwd()
+		if err != nil {
+			return "", err
+		}
+
+		p = r.slash(wd) + "/" + p
+	}
+
+	if r.Windows && reDrive.MatchString(p) {
+		p = "/" + strings.ToUpper(p[:1]) + p[1:]
+	}
+
+	u := &url.URL{
+		Scheme: "file",
+		Host:   host,
+		Path:   path.Clean(p),
+	}
+
+	// Percent encoding leaves '@' intact, which is escaped too; see escapeAt
+	u.RawPath = strings.ReplaceAll(u.EscapedPath(), "@", "%40")
+
+	return u.String(), nil
+}
+
+func (r *LocalRules) isBareLocal(in string) bool {
+	if r.Exists == nil || strings.Contains(in, "://") {
+		return false
+	}
+
+	first := in
+	if i := strings.IndexAny(in, r.separators()); i >= 0 {
+		first = in[:i]
+	}
+
+	first, _, _ = parseRef(first)
+
+	if first == "" || strings.Contains(first, ":") || !r.Exists(first) {
+		return false
+	}
+
+	if !strings.Contains(first, ".") {
+		return true
+	}
+
+	_, class, _, ok := parseClass(first)
+
+	return ok && supportedClasses.contains(class)
+}
+
+func (r *LocalRules) isAbs(p string) bool {
+	return strings.HasPrefix(p, "/") || r.Windows && reDrive.MatchString(p)
+}
+
+func (r *LocalRules) separators() string {
+	if r.Windows {
+		return `/\`
+	}
+
+	return "/"
+}
+
+func (r *LocalRules) slash(p string) string {
+	if r.Windows {
+		return strings.ReplaceAll(p, `\`, "/")
+	}
+
+	return p
+}
+
+// isDriveHost reports whether the host of a file URL is in fact a Windows drive, e.g. "file://C:/x".
+func isDriveHost(host string) bool {
+	return reDriveHost.MatchString(host)
+}
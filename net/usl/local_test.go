@@ -0,0 +1,203 @@
+package usl
+
+import (
+	"testing"
+)
+
+func testLocalRules(windows bool) *LocalRules {
+	home, wd := "/home/user", "/work"
+	if windows {
+		home, wd = `C:\Users\user`, `D:\work`
+	}
+
+	return &LocalRules{
+		Windows: windows,
+		Home:    func() (string, error) { return home, nil },
+		Getwd:   func() (string, error) { return wd, nil },
+		Exists: func(name string) bool {
+			return name == "repo.git" || name == "repo" || name == "example.com"
+		},
+	}
+}
+
+//nolint:funlen
+func TestLocalRules(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]testParse{
+		"POSIX": {
+			{
+				"~/src/repo", map[string]string{
+					"source": "file:///home/user/src/repo",
+				},
+			},
+			{
+				"~", map[string]string{
+					"source": "file:///home/user",
+				},
+			},
+			{
+				".", map[string]string{
+					"source": "file:///work",
+				},
+			},
+			{
+				"./my repo/ünï.tar.gz/x", map[string]string{
+					"source": "file:///work/my%20repo/%C3%BCn%C3%AF.tar.gz",
+
+					"class":  "tar.gz",
+					"inpath": "x",
+					"path":   "/work/my repo/ünï",
+				},
+			},
+			{
+				"repo.git/sub@next", map[string]string{
+					"source": "file:///work/repo",
+
+					"class":  "git",
+					"inpath": "sub",
+					"ref":    "next",
+				},
+			},
+			{
+				"./@scope/50%/repo.git@v1", map[string]string{
+					"source": "file:///work/%40scope/50%25/repo",
+
+					"path": "/work/@scope/50%/repo",
+					"ref":  "v1",
+				},
+			},
+			{
+				"/tmp/a@b/repo", map[string]string{
+					"source": "file:///tmp/a%40b/repo",
+
+					"path": "/tmp/a@b/repo",
+					"ref":  "",
+				},
+			},
+			{
+				"./a%40b/c%2Fd", map[string]string{
+					"source": "file:///work/a%2540b/c%252Fd",
+
+					"path": "/work/a%40b/c%2Fd",
+				},
+			},
+			{
+				"repo", map[string]string{
+					"source": "file:///work/repo",
+				},
+			},
+			{
+				"example.com/repo", map[string]string{
+					"source": "https://example.com/repo",
+				},
+			},
+			{
+				"file:///C:/x", map[string]string{
+					"source": "file:///C:/x",
+				},
+			},
+			{
+				"file://c:/x", map[string]string{
+					"source": "file:///C:/x",
+
+					"host": "",
+				},
+			},
+		},
+		"Windows": {
+			{
+				`C:\src\repo`, map[string]string{
+					"source": "file:///C:/src/repo",
+				},
+			},
+			{
+				`c:/src/repo.git/a`, map[string]string{
+					"source": "file:///C:/src/repo",
+
+					"class":  "git",
+					"inpath": "a",
+				},
+			},
+			{
+				`\\server\share\repo.tar.gz`, map[string]string{
+					"source": "file://server/share/repo.tar.gz",
+
+					"class": "tar.gz",
+					"host":  "server",
+				},
+			},
+			{
+				`~\src\repo`, map[string]string{
+					"source": "file:///C:/Users/user/src/repo",
+				},
+			},
+			{
+				`.\sub dir\..\repo`, map[string]string{
+					"source": "file:///D:/work/repo",
+				},
+			},
+			{
+				`repo.git`, map[string]string{
+					"source": "file:///D:/work/repo",
+
+					"class": "git",
+				},
+			},
+		},
+	}
+
+	for name, ts := range tests {
+		ts := ts // https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		rules := testLocalRules(name == "Windows")
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, tc := range ts {
+				got, err := rules.ParseMayLocalPath(tc.in)
+
+				if err != nil {
+					t.Errorf("ParseMayLocalPath(%q) = unexpected err %q", tc.in, err)
+					continue
+				}
+
+				m, _ := got.Map()
+
+				for ke, ve := range tc.out {
+					if va, ok := m[ke]; ok {
+						if ve != va {
+							t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestIsLocal(t *testing.T) {
+	t.Parallel()
+
+	posix, windows := testLocalRules(false), testLocalRules(true)
+
+	for in, want := range map[string][2]bool{
+		"/a":           {true, true},
+		"./a":          {true, true},
+		"~/a":          {true, true},
+		"..":           {true, true},
+		`.\a`:          {false, true},
+		`C:\a`:         {false, true},
+		`\\server\a`:   {false, true},
+		"github.com/a": {false, false},
+		"host:a":       {false, false},
+		"~user/a":      {false, false},
+	} {
+		if got := posix.IsLocal(in); got != want[0] {
+			t.Errorf("POSIX IsLocal(%q) = %v", in, got)
+		}
+
+		if got := windows.IsLocal(in); got != want[1] {
+			t.Errorf("Windows IsLocal(%q) = %v", in, got)
+		}
+	}
+}
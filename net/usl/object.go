@@ -0,0 +1,159 @@
+package usl
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Object storage schemes
+const (
+	s3Scheme    = "s3"
+	gsScheme    = "gs"
+	azureScheme = "az"
+)
+
+type objectStorage struct {
+	bucket  *regexp.Regexp // Valid bucket (or container) names
+	version string         // Query parameter holding the object version
+}
+
+var objectStorages = map[string]objectStorage{
+	s3Scheme: {
+		bucket:  regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`),
+		version: "versionId",
+	},
+	gsScheme: {
+		bucket:  regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,220}[a-z0-9]$`),
+		version: "generation",
+	},
+	azureScheme: {
+		bucket:  regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`),
+		version: "versionid",
+	},
+}
+
+// Object storage HTTPS endpoints in virtual-hosted (bucket in host) and path (bucket in path) styles
+var (
+	reS3Host = regexp.MustCompile(
+		`^(?:(?P<bucket>[a-z0-9][a-z0-9.-]*)\.)?s3(?:[.-](?:dualstack\.)?(?P<region>[a-z0-9-]+))?\.amazonaws\.com$`,
+	)
+	reGSHost = regexp.MustCompile(
+		`^(?:(?P<bucket>[a-z0-9][a-z0-9._-]*)\.)?storage\.(?:googleapis|cloud\.google)\.com$`,
+	)
+	reAzureHost = regexp.MustCompile(
+		`^(?P<account>[a-z0-9]+)\.blob\.core\.windows\.net$`,
+	)
+)
+
+func isObjectStorage(scheme string) bool {
+	_, ok := objectStorages[scheme]
+
+	return ok
+}
+
+// fromObjectStorageURL turns an HTTPS URL of a known object storage endpoint into the object storage form, e.g.
+// "https://bucket.s3.amazonaws.com/key" into "s3://bucket/key".
+func (us *USL) fromObjectStorageURL() bool {
+	if us.Scheme != "https" && us.Scheme != "http" {
+		return false
+	}
+
+	var (
+		m      map[string]string
+		ok     bool
+		scheme string
+	)
+
+	if m, ok = namedMatches(reS3Host, us.Domain); ok {
+		scheme = s3Scheme
+		us.Region = m["region"]
+	} else if m, ok = namedMatches(reGSHost, us.Domain); ok {
+		scheme = gsScheme
+	} else if m, ok = namedMatches(reAzureHost, us.Domain); ok {
+		scheme = azureScheme
+		us.Account = m["account"]
+	} else {
+		return false
+	}
+
+	bucket, key := m["bucket"], relPath(us.Path)
+	if bucket == "" {
+		bucket, key = cut(key, "/")
+	}
+
+	us.Scheme = scheme
+	us.Host = bucket
+	us.Domain = bucket
+	us.Port = ""
+	us.Path = "/" + key
+
+	return true
+}
+
+func (us *USL) computeObject() error {
+	storage := objectStorages[us.Scheme]
+
+	us.Bucket = us.Host
+	us.Key = relPath(us.Path)
+
+	if !storage.bucket.MatchString(us.Bucket) {
+		return fmt.Errorf("invalid %s bucket name %q", us.Scheme, us.Bucket)
+	}
+
+	if us.Key == "" {
+		return fmt.Errorf("missing object key for %s bucket %q", us.Scheme, us.Bucket)
+	}
+
+	if region := us.query.Get("region"); region != "" {
+		us.Region = region
+	}
+
+	us.Version = us.query.Get(storage.version)
+
+	us.Name = us.Bucket + "/" + us.Key
+	us.BasePath = us.Key
+
+	if before, class, after, ok := parseClass(us.Key); ok && supportedClasses.contains(class) && class != "git" {
+		us.Name = us.Bucket + "/" + before
+		us.InPath = relPath(after)
+		us.Class = Class(class)
+		us.Key = before + "." + class
+		us.BasePath = us.Key
+	}
+
+	us.Path = "/" + us.Key
+	us.computeNames()
+	us.Source = us.objectSource()
+
+	id := us.Source
+	if us.Version != "" {
+		id += "@" + us.Version
+	}
+
+	us.ID = url.PathEscape(id)
+
+	return nil
+}
+
+// objectSource returns the object storage URL of the object which is the HTTPS URL for Azure, as there is no widely
+// accepted URL form including the storage account.
+func (us *USL) objectSource() string {
+	var buf strings.Builder
+
+	if us.Scheme == azureScheme && us.Account != "" {
+		buf.WriteString("https://")
+		buf.WriteString(us.Account)
+		buf.WriteString(".blob.core.windows.net/")
+	} else {
+		buf.WriteString(us.Scheme)
+		buf.WriteString("://")
+	}
+
+	buf.WriteString(us.Bucket)
+	buf.WriteByte('/')
+	buf.WriteString(us.Key)
+
+	return buf.String()
+}
@@ -0,0 +1,163 @@
+package usl
+
+import (
+	"testing"
+)
+
+//nolint:funlen
+func TestObjectStorage(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]testParse{
+		"Schemes": {
+			{
+				"s3://my-bucket/dist/x.tar.gz/inner?versionId=abc&region=eu-west-1", map[string]string{
+					"source": "s3://my-bucket/dist/x.tar.gz",
+
+					"bucket":  "my-bucket",
+					"class":   "tar.gz",
+					"id":      `s3:%2F%2Fmy-bucket%2Fdist%2Fx.tar.gz@abc`,
+					"inpath":  "inner",
+					"key":     "dist/x.tar.gz",
+					"name":    "my-bucket/dist/x",
+					"region":  "eu-west-1",
+					"scheme":  "s3",
+					"version": "abc",
+				},
+			},
+			{
+				"gs://bucket/path/to/object?generation=1360887759327000", map[string]string{
+					"source": "gs://bucket/path/to/object",
+
+					"bucket":  "bucket",
+					"class":   "",
+					"key":     "path/to/object",
+					"scheme":  "gs",
+					"version": "1360887759327000",
+				},
+			},
+			{
+				"az://container/blob.zip", map[string]string{
+					"source": "az://container/blob.zip",
+
+					"account": "",
+					"bucket":  "container",
+					"class":   "zip",
+					"key":     "blob.zip",
+				},
+			},
+		},
+		"Virtual-hosted style": {
+			{
+				"https://my-bucket.s3.us-west-2.amazonaws.com/a/b.zip", map[string]string{
+					"source": "s3://my-bucket/a/b.zip",
+
+					"bucket": "my-bucket",
+					"class":  "zip",
+					"key":    "a/b.zip",
+					"region": "us-west-2",
+					"scheme": "s3",
+				},
+			},
+			{
+				"my.bucket.s3.amazonaws.com/key", map[string]string{
+					"source": "s3://my.bucket/key",
+
+					"bucket": "my.bucket",
+					"region": "",
+				},
+			},
+			{
+				"https://bucket.storage.googleapis.com/a.tgz", map[string]string{
+					"source": "gs://bucket/a.tgz",
+
+					"bucket": "bucket",
+					"class":  "tgz",
+				},
+			},
+			{
+				"https://acct.blob.core.windows.net/cont/dir/f.tar.zst?versionid=2019-01-01T00:00:00Z", map[string]string{
+					"source": "https://acct.blob.core.windows.net/cont/dir/f.tar.zst",
+
+					"account": "acct",
+					"bucket":  "cont",
+					"class":   "tar.zst",
+					"key":     "dir/f.tar.zst",
+					"scheme":  "az",
+					"version": "2019-01-01T00:00:00Z",
+				},
+			},
+		},
+		"Path style": {
+			{
+				"https://s3.amazonaws.com/bucket/key", map[string]string{
+					"source": "s3://bucket/key",
+
+					"bucket": "bucket",
+					"key":    "key",
+					"region": "",
+				},
+			},
+			{
+				"https://s3-eu-west-1.amazonaws.com/bucket/a/b.txz", map[string]string{
+					"source": "s3://bucket/a/b.txz",
+
+					"bucket": "bucket",
+					"class":  "txz",
+					"name":   "bucket/a/b",
+					"region": "eu-west-1",
+				},
+			},
+			{
+				"storage.googleapis.com/bucket/object", map[string]string{
+					"source": "gs://bucket/object",
+
+					"bucket": "bucket",
+					"key":    "object",
+					"scheme": "gs",
+				},
+			},
+		},
+	}
+
+	for name, ts := range tests {
+		ts := ts // https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, tc := range ts {
+				got, err := Parse(tc.in)
+
+				if err != nil {
+					t.Errorf("Parse(%q) = unexpected err %q", tc.in, err)
+					continue
+				}
+
+				m, _ := got.Map()
+
+				for ke, ve := range tc.out {
+					if va, ok := m[ke]; ok {
+						if ve != va {
+							t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestObjectStorageInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{
+		"s3://bucket",
+		"s3://b/key",
+		"az://under_score/key",
+		"https://s3.amazonaws.com/",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = expected error", in)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package usl
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const ociScheme = "oci"
+
+var (
+	supportedRegistries = newSupported(
+		"docker.io",
+		"gcr.io",
+		"ghcr.io",
+		"mcr.microsoft.com",
+		"public.ecr.aws",
+		"quay.io",
+		"registry.gitlab.com",
+	)
+
+	// Registry domain suffixes of the cloud providers hosting registries per account or region
+	supportedRegistrySuffixes = []string{
+		".azurecr.io",
+		".gcr.io",
+		".pkg.dev",
+	}
+)
+
+// Patterns from the OCI distribution specification
+const (
+	ociComponentPattern = `[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*`
+	ociNamePattern      = `(?P<name>` + ociComponentPattern + `(?:/` + ociComponentPattern + `)*)`
+	ociTagPattern       = `(?::(?P<tag>[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}))?`
+	ociDigestPattern    = `(?:@(?P<digest>[a-z0-9]+(?:[+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]+))?`
+)
+
+var (
+	reOCI = regexp.MustCompile(
+		`^(?P<host>[a-zA-Z0-9.-]+(?::[0-9]+)?)/` + ociNamePattern + ociTagPattern + ociDigestPattern + `$`,
+	)
+
+	reECRHost = regexp.MustCompile(`^[0-9]+\.dkr\.ecr\.[a-z0-9-]+\.amazonaws\.com$`)
+
+	reOCIPath = regexp.MustCompile(`^/?` + ociNamePattern + ociTagPattern + ociDigestPattern + `$`)
+
+	reOCIDigests = map[string]*regexp.Regexp{
+		"sha256": regexp.MustCompile(`^[a-f0-9]{64}$`),
+		"sha512": regexp.MustCompile(`^[a-f0-9]{128}$`),
+	}
+)
+
+// Reference returns the reference of an OCI artifact in the form accepted by container tools, i.e.
+// "host/name:tag@digest".  It returns an empty string for other classes.
+func (us *USL) Reference() string {
+	if us.Class != ociScheme {
+		return ""
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString(us.Host)
+	buf.WriteByte('/')
+	buf.WriteString(us.Name)
+
+	if us.Ref != "" {
+		buf.WriteByte(':')
+		buf.WriteString(us.Ref)
+	}
+
+	if us.Digest != "" {
+		buf.WriteByte('@')
+		buf.WriteString(us.Digest)
+	}
+
+	return buf.String()
+}
+
+func isRegistry(host string) bool {
+	if supportedRegistries.contains(host) || reECRHost.MatchString(host) {
+		return true
+	}
+
+	for _, suffix := range supportedRegistrySuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchOCI(in string) (map[string]string, bool) {
+	m, ok := namedMatches(reOCI, in)
+	if !ok || !isRegistry(strings.ToLower(m["host"])) {
+		return nil, false
+	}
+
+	return m, true
+}
+
+func parseOCI(in string, match map[string]string) (*url.URL, error) {
+	if match == nil {
+		var ok bool
+
+		if match, ok = namedMatches(reOCI, in); !ok {
+			return nil, fmt.Errorf("invalid oci reference %q", in)
+		}
+	}
+
+	path := "/" + match["name"]
+
+	if match["tag"] != "" {
+		path += ":" + match["tag"]
+	}
+
+	if match["digest"] != "" {
+		path += "@" + match["digest"]
+	}
+
+	return &url.URL{
+		Host:   strings.ToLower(match["host"]),
+		Path:   path,
+		Scheme: ociScheme,
+	}, nil
+}
+
+func (us *USL) computeOCI() error {
+	m, ok := namedMatches(reOCIPath, us.Path)
+	if !ok {
+		return fmt.Errorf("invalid oci repository path %q", us.Path)
+	}
+
+	us.Class = ociScheme
+	us.Name = m["name"]
+	us.Ref = m["tag"]
+	us.Digest = m["digest"]
+
+	if us.Domain == "docker.io" && !strings.Contains(us.Name, "/") {
+		us.Name = "library/" + us.Name
+	}
+
+	if us.Digest != "" {
+		algorithm, encoded := cut(us.Digest, ":")
+		if re, ok := reOCIDigests[algorithm]; ok && !re.MatchString(encoded) {
+			return fmt.Errorf("invalid %s digest %q", algorithm, us.Digest)
+		}
+	}
+
+	us.Path = "/" + us.Name
+	us.BasePath = us.Name
+	us.computeNames()
+	us.Source = ociScheme + "://" + us.Host + "/" + us.Name
+	us.ID = url.PathEscape(ociScheme + "://" + us.Reference())
+
+	return nil
+}
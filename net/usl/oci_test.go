@@ -0,0 +1,115 @@
+package usl
+
+import (
+	"testing"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+//nolint:funlen
+func TestOCI(t *testing.T) {
+	t.Parallel()
+
+	tests := []testParse{
+		{
+			"oci://ghcr.io/org/artifact:tag@" + testDigest, map[string]string{
+				"source": "oci://ghcr.io/org/artifact",
+
+				"class":  "oci",
+				"digest": testDigest,
+				"domain": "ghcr.io",
+				"id":     `oci:%2F%2Fghcr.io%2Forg%2Fartifact:tag@` + testDigest,
+				"name":   "org/artifact",
+				"ref":    "tag",
+				"scheme": "oci",
+			},
+		},
+		{
+			"ghcr.io/org/image:1.2", map[string]string{
+				"source": "oci://ghcr.io/org/image",
+
+				"class":  "oci",
+				"digest": "",
+				"name":   "org/image",
+				"ref":    "1.2",
+			},
+		},
+		{
+			"docker.io/nginx@" + testDigest, map[string]string{
+				"source": "oci://docker.io/library/nginx",
+
+				"class":  "oci",
+				"digest": testDigest,
+				"name":   "library/nginx",
+				"ref":    "",
+			},
+		},
+		{
+			"123456789012.dkr.ecr.us-east-1.amazonaws.com/team/app:v1", map[string]string{
+				"source": "oci://123456789012.dkr.ecr.us-east-1.amazonaws.com/team/app",
+
+				"class": "oci",
+				"name":  "team/app",
+				"ref":   "v1",
+			},
+		},
+		{
+			"oci://localhost:5000/a/b:latest", map[string]string{
+				"source": "oci://localhost:5000/a/b",
+
+				"class":  "oci",
+				"domain": "localhost",
+				"name":   "a/b",
+				"port":   "5000",
+				"ref":    "latest",
+			},
+		},
+		{
+			"example.com/org/image:1.2", map[string]string{
+				"source": "https://example.com/org/image:1.2",
+
+				"class":  "",
+				"scheme": "https",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := Parse(tc.in)
+
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", tc.in, err)
+			continue
+		}
+
+		m, _ := got.Map()
+
+		for ke, ve := range tc.out {
+			if va, ok := m[ke]; ok {
+				if ve != va {
+					t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+				}
+			}
+		}
+	}
+
+	us, _ := Parse("ghcr.io/org/image:1.2@" + testDigest)
+	if want := "ghcr.io/org/image:1.2@" + testDigest; us.Reference() != want {
+		t.Errorf("Reference() want: %s\tgot:  %s", want, us.Reference())
+	}
+}
+
+func TestOCIInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{
+		"oci://ghcr.io/Org/image",
+		"oci://ghcr.io/org/image:-tag",
+		"oci://ghcr.io/org/image@sha256:1234",
+		"oci://ghcr.io",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = expected error", in)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package usl
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const purlScheme = "pkg"
+
+// Package URL types which map one to one to a supported provider
+var purlProviders = map[string]string{
+	"bitbucket": "bitbucket.org",
+	"github":    "github.com",
+	"gitlab":    "gitlab.com",
+}
+
+func purlTypeOf(host string) (string, bool) {
+	for typ, provider := range purlProviders {
+		if provider == host {
+			return typ, true
+		}
+	}
+
+	return "", false
+}
+
+// PURL returns the Package URL (see https://github.com/package-url/purl-spec) of the source.  Sources on providers
+// known to the spec are converted to the provider types (e.g. "pkg:github/user/repo@ref#sub/path"), others to the
+// "generic" type with the source given as a "vcs_url" or "download_url" qualifier.
+func (us *USL) PURL() (string, error) {
+	if us.Name == "" {
+		return "", fmt.Errorf("cannot convert source without a name to package url: %q", us.Source)
+	}
+
+	if us.Class == ociScheme {
+		return us.ociPURL(), nil
+	}
+
+	var (
+		typ        string
+		qualifiers = map[string]string{}
+	)
+
+	if t, ok := purlTypeOf(us.Host); ok && us.Class == "git" {
+		typ = t
+	} else {
+		typ = "generic"
+
+		if us.Class.IsVCS() {
+			// Sources of the other version control systems could have the class in the scheme already
+			qualifiers["vcs_url"] = us.Source
+			if !strings.HasPrefix(us.Source, us.Class.String()+"+") {
+				qualifiers["vcs_url"] = us.Class.String() + "+" + us.Source
+			}
+		} else {
+			qualifiers["download_url"] = us.Source
+		}
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString(purlScheme)
+	buf.WriteByte(':')
+	buf.WriteString(typ)
+
+	for _, segment := range strings.Split(us.Name, "/") {
+		buf.WriteByte('/')
+		buf.WriteString(purlEscape(segment, ""))
+	}
+
+	if us.Ref != "" {
+		buf.WriteByte('@')
+		buf.WriteString(purlEscape(us.Ref, ""))
+	}
+
+	writePURLQualifiers(&buf, qualifiers)
+
+	if us.InPath != "" {
+		buf.WriteByte('#')
+
+		for i, segment := range strings.Split(us.InPath, "/") {
+			if i > 0 {
+				buf.WriteByte('/')
+			}
+
+			buf.WriteString(purlEscape(segment, ""))
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// ParsePURL parses a Package URL produced by PURL or written by hand into a USL.  Versions are references of the
+// sources except for the ones given by a "download_url" qualifier, where they are ignored.  Subpaths having "." or ".."
+// segments are rejected.
+func ParsePURL(purl string) (*USL, error) { //nolint:funlen
+	scheme, remaining := cut(purl, ":")
+	if remaining == "" || strings.ToLower(scheme) != purlScheme {
+		return nil, fmt.Errorf("not a package url: %q", purl)
+	}
+
+	remaining = strings.TrimLeft(remaining, "/")
+
+	remaining, subpath := cut(remaining, "#")
+	remaining, query := cut(remaining, "?")
+
+	version := ""
+	if i := strings.LastIndex(remaining, "@"); i >= 0 {
+		remaining, version = remaining[:i], remaining[i+1:]
+	}
+
+	typ, name := cut(remaining, "/")
+	typ = strings.ToLower(typ)
+
+	if typ == "" || name == "" {
+		return nil, fmt.Errorf("incomplete package url: %q", purl)
+	}
+
+	name, err := purlUnescapePath(strings.Trim(name, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if version, err = url.PathUnescape(version); err != nil {
+		return nil, err
+	}
+
+	if subpath, err = purlUnescapePath(strings.Trim(subpath, "/")); err != nil {
+		return nil, err
+	}
+
+	for _, segment := range strings.Split(subpath, "/") {
+		if segment == "." || segment == ".." {
+			return nil, fmt.Errorf("invalid subpath segment %q in package url: %q", segment, purl)
+		}
+	}
+
+	qualifiers, err := parsePURLQualifiers(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var in string
+
+	switch {
+	case purlProviders[typ] != "":
+		in = purlProviders[typ] + "/" + name
+	case typ == "generic" && qualifiers["vcs_url"] != "":
+		vcs, source := cut(qualifiers["vcs_url"], "+")
+		if source == "" || !Class(vcs).IsVCS() {
+			return nil, fmt.Errorf("unsupported vcs url in package url: %q", qualifiers["vcs_url"])
+		}
+
+		in = qualifiers["vcs_url"]
+		if vcs == "git" {
+			in = source
+		}
+	case typ == ociScheme && qualifiers["repository_url"] != "":
+		in = ociScheme + "://" + qualifiers["repository_url"]
+
+		if qualifiers["tag"] != "" {
+			in += ":" + qualifiers["tag"]
+		}
+	case typ == "generic" && qualifiers["download_url"] != "":
+		// Versions of downloads are informational, since the download url points to a single version already
+		in, version = qualifiers["download_url"], ""
+	default:
+		return nil, fmt.Errorf("unsupported package url type %q", typ)
+	}
+
+	if version != "" {
+		in += "@" + version
+	}
+
+	us, err := Parse(in)
+	if err != nil {
+		return nil, err
+	}
+
+	us.InPath = subpath
+
+	return us, nil
+}
+
+// purlEscape percent encodes a package url component leaving the unreserved characters and the given extra
+// characters intact.
+func purlEscape(s string, extra string) string {
+	var buf strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if isUnreserved(c) || strings.IndexByte(extra, c) >= 0 {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+
+	return buf.String()
+}
+
+// ociPURL returns the Package URL of an OCI artifact where the version is the digest and the repository is given in
+// qualifiers.
+func (us *USL) ociPURL() string {
+	var buf strings.Builder
+
+	buf.WriteString(purlScheme)
+	buf.WriteByte(':')
+	buf.WriteString(ociScheme)
+	buf.WriteByte('/')
+	buf.WriteString(purlEscape(us.Name[strings.LastIndex(us.Name, "/")+1:], ""))
+
+	if us.Digest != "" {
+		buf.WriteByte('@')
+		buf.WriteString(purlEscape(us.Digest, ""))
+	}
+
+	qualifiers := map[string]string{"repository_url": us.Host + "/" + us.Name}
+	if us.Ref != "" {
+		qualifiers["tag"] = us.Ref
+	}
+
+	writePURLQualifiers(&buf, qualifiers)
+
+	return buf.String()
+}
+
+func writePURLQualifiers(buf *strings.Builder, qualifiers map[string]string) {
+	keys := make([]string, 0, len(qualifiers))
+
+	for k := range qualifiers {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i == 0 {
+			buf.WriteByte('?')
+		} else {
+			buf.WriteByte('&')
+		}
+
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(purlEscape(qualifiers[k], ":/+"))
+	}
+}
+
+// parsePURLQualifiers parses the qualifiers which, unlike a URL query, keep '+' as is.
+func parsePURLQualifiers(query string) (map[string]string, error) {
+	qualifiers := map[string]string{}
+
+	if query == "" {
+		return qualifiers, nil
+	}
+
+	for _, pair := range strings.Split(query, "&") {
+		k, v := cut(pair, "=")
+
+		value, err := url.PathUnescape(v)
+		if err != nil {
+			return nil, err
+		}
+
+		qualifiers[strings.ToLower(k)] = value
+	}
+
+	return qualifiers, nil
+}
+
+func purlUnescapePath(s string) (string, error) {
+	segments := strings.Split(s, "/")
+
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", err
+		}
+
+		segments[i] = unescaped
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0
+}
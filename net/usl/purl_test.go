@@ -0,0 +1,183 @@
+package usl
+
+import (
+	"testing"
+)
+
+type testPURL struct {
+	in   string
+	purl string
+}
+
+//nolint:funlen
+func TestPURL(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]testPURL{
+		"Providers": {
+			{
+				"github.com/user/repo",
+				"pkg:github/user/repo",
+			},
+			{
+				"github.com/user/repo/a/b@v1.2",
+				"pkg:github/user/repo@v1.2#a/b",
+			},
+			{
+				"git@gitlab.com:user/repo.git@next",
+				"pkg:gitlab/user/repo@next",
+			},
+			{
+				"bitbucket.org/user/repo@feature/x",
+				"pkg:bitbucket/user/repo@feature%2Fx",
+			},
+		},
+		"Generic": {
+			{
+				"salsa.debian.org/user/repo@v1",
+				"pkg:generic/user/repo@v1?vcs_url=git+https://salsa.debian.org/user/repo.git",
+			},
+			{
+				"https://example.com/user/repo.git/a",
+				"pkg:generic/user/repo?vcs_url=git+https://example.com/user/repo.git#a",
+			},
+			{
+				"hg+https://example.com/user/repo@default",
+				"pkg:generic/user/repo@default?vcs_url=hg+https://example.com/user/repo",
+			},
+			{
+				"hg+https://bitbucket.org/user/repo/sub",
+				"pkg:generic/user/repo?vcs_url=hg+https://bitbucket.org/user/repo#sub",
+			},
+			{
+				"svn+ssh://example.com/repo@42",
+				"pkg:generic/repo@42?vcs_url=svn+ssh://example.com/repo",
+			},
+			{
+				"svn://example.com/repo@42",
+				"pkg:generic/repo@42?vcs_url=svn+svn://example.com/repo",
+			},
+			{
+				"https://example.com/dist/repo.tar.gz/a/b",
+				"pkg:generic/dist/repo?download_url=https://example.com/dist/repo.tar.gz#a/b",
+			},
+			{
+				"ghcr.io/org/image:1.2@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+				"pkg:oci/image@sha256%3A0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" +
+					"?repository_url=ghcr.io/org/image&tag=1.2",
+			},
+			{
+				"https://example.com:8080/dist/repo.zip",
+				"pkg:generic/dist/repo?download_url=https://example.com:8080/dist/repo.zip",
+			},
+		},
+	}
+
+	for name, ts := range tests {
+		ts := ts // https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, tc := range ts {
+				us, err := Parse(tc.in)
+				if err != nil {
+					t.Errorf("Parse(%q) = unexpected err %q", tc.in, err)
+					continue
+				}
+
+				got, err := us.PURL()
+				if err != nil {
+					t.Errorf("PURL(%q) = unexpected err %q", tc.in, err)
+					continue
+				}
+
+				if got != tc.purl {
+					t.Errorf("\t%40s    want: %-12s\tgot:  %-12s", tc.in, tc.purl, got)
+				}
+
+				back, err := ParsePURL(got)
+				if err != nil {
+					t.Errorf("ParsePURL(%q) = unexpected err %q", got, err)
+					continue
+				}
+
+				want, _ := us.Map()
+				have, _ := back.Map()
+
+				for _, k := range []string{"class", "digest", "domain", "name", "ref", "inpath"} {
+					if want[k] != have[k] {
+						t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, k, want[k], have[k])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParsePURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []testParse{
+		{
+			"pkg:generic/openssl@1.1.1?download_url=https://openssl.org/source/openssl-1.1.1.tar.gz", map[string]string{
+				"source": "https://openssl.org/source/openssl-1.1.1.tar.gz",
+
+				"class": "tar.gz",
+				"ref":   "",
+			},
+		},
+		{
+			"pkg:generic/repo@42?vcs_url=svn+ssh://example.com/repo", map[string]string{
+				"source": "svn+ssh://example.com/repo",
+
+				"class":  "svn",
+				"ref":    "42",
+				"scheme": "svn+ssh",
+			},
+		},
+		{
+			"pkg:github/user/repo@v1#a/b", map[string]string{
+				"source": "https://github.com/user/repo.git",
+
+				"inpath": "a/b",
+				"ref":    "v1",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := ParsePURL(tc.in)
+		if err != nil {
+			t.Errorf("ParsePURL(%q) = unexpected err %q", tc.in, err)
+			continue
+		}
+
+		m, _ := got.Map()
+
+		for ke, ve := range tc.out {
+			if va, ok := m[ke]; ok {
+				if ve != va {
+					t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+				}
+			}
+		}
+	}
+}
+
+func TestParsePURLInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{
+		"github.com/user/repo",
+		"pkg:",
+		"pkg:github",
+		"pkg:npm/foo@1.0",
+		"pkg:generic/foo?vcs_url=cvs+https://example.com/foo",
+		"pkg:github/user/repo#a/../../x",
+		"pkg:github/user/repo#./x",
+	} {
+		if _, err := ParsePURL(in); err == nil {
+			t.Errorf("ParsePURL(%q) = expected error", in)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package usl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RefKind is the kind of a git reference.
+type RefKind string
+
+// Kinds of git references
+const (
+	RefSymbolic  RefKind = "symbolic" // Branch, tag or any other reference name
+	RefShortHash RefKind = "short"    // Abbreviated object ID
+	RefSHA1      RefKind = "sha1"     // Full SHA-1 object ID
+	RefSHA256    RefKind = "sha256"   // Full SHA-256 object ID
+)
+
+// Shortest abbreviated object ID recognized, which is the default abbreviation length of git.  Shorter hexadecimal
+// names (e.g. "cafe") are considered symbolic.
+const minShortHash = 7
+
+var reHex = regexp.MustCompile(`^[0-9a-f]+$`)
+
+// GitRefKind returns the kind of the given git reference.  Names consisting of lower case hexadecimal digits are
+// considered object IDs according to their lengths.
+func GitRefKind(ref string) RefKind {
+	if !reHex.MatchString(ref) {
+		return RefSymbolic
+	}
+
+	switch n := len(ref); {
+	case n == 40:
+		return RefSHA1
+	case n == 64:
+		return RefSHA256
+	case n >= minShortHash && n < 64:
+		return RefShortHash
+	}
+
+	return RefSymbolic
+}
+
+// CheckRefFormat checks whether the given name is a valid git reference name following the rules of
+// git-check-ref-format(1), where one level names such as "main" are allowed.
+func CheckRefFormat(name string) error { //nolint:gocyclo
+	switch {
+	case name == "":
+		return fmt.Errorf("empty git reference")
+	case name == "@":
+		return fmt.Errorf("invalid git reference %q: cannot be the single character '@'", name)
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return fmt.Errorf("invalid git reference %q: cannot begin or end with a slash", name)
+	case strings.HasSuffix(name, "."):
+		return fmt.Errorf("invalid git reference %q: cannot end with a dot", name)
+	case strings.Contains(name, "//"):
+		return fmt.Errorf("invalid git reference %q: cannot contain consecutive slashes", name)
+	case strings.Contains(name, ".."):
+		return fmt.Errorf("invalid git reference %q: cannot contain '..'", name)
+	case strings.Contains(name, "@{"):
+		return fmt.Errorf("invalid git reference %q: cannot contain '@{'", name)
+	}
+
+	if i := strings.IndexFunc(name, isInvalidRefRune); i >= 0 {
+		return fmt.Errorf("invalid git reference %q: cannot contain %q", name, name[i:i+1])
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return fmt.Errorf("invalid git reference %q: component cannot begin with a dot", name)
+		}
+
+		if strings.HasSuffix(component, ".lock") {
+			return fmt.Errorf("invalid git reference %q: component cannot end with '.lock'", name)
+		}
+	}
+
+	return nil
+}
+
+func isInvalidRefRune(r rune) bool {
+	return isSpaceOrControl(r) || strings.ContainsRune(`~^:?*[\`, r)
+}
+
+// refKind returns the kind of the reference for git USLs, or an empty string otherwise.
+func (us *USL) refKind() RefKind {
+	if us.Class != "git" || us.Ref == "" {
+		return ""
+	}
+
+	return GitRefKind(us.Ref)
+}
@@ -0,0 +1,140 @@
+package usl
+
+import (
+	"testing"
+)
+
+func TestCheckRefFormat(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		"main",
+		"v1.0.0",
+		"feature/x",
+		"refs/heads/main",
+		"release-2024",
+		"a.b/c-d_e",
+	}
+
+	invalid := []string{
+		"",
+		"@",
+		"/main",
+		"main/",
+		"main.",
+		"a//b",
+		"a..b",
+		"a@{1}",
+		"a b",
+		"a~1",
+		"a^2",
+		"a:b",
+		"a?",
+		"a*",
+		"a[b",
+		`a\b`,
+		"a\x7f",
+		".hidden",
+		"a/.hidden",
+		"main.lock",
+		"a.lock/b",
+	}
+
+	for _, name := range valid {
+		if err := CheckRefFormat(name); err != nil {
+			t.Errorf("CheckRefFormat(%q) = unexpected err %q", name, err)
+		}
+	}
+
+	for _, name := range invalid {
+		if err := CheckRefFormat(name); err == nil {
+			t.Errorf("CheckRefFormat(%q) = expected error", name)
+		}
+	}
+}
+
+func TestGitRefKind(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]RefKind{
+		"main":                       RefSymbolic,
+		"v1.0.0":                     RefSymbolic,
+		"cafe":                       RefSymbolic,
+		"abcdef":                     RefSymbolic,
+		"ABCDEF0123":                 RefSymbolic,
+		"abcdef0":                    RefShortHash,
+		testCommit[:12]:              RefShortHash,
+		testCommit:                   RefSHA1,
+		testCommit + testCommit[:24]: RefSHA256,
+		testCommit + testCommit[:25]: RefSymbolic,
+	}
+
+	for ref, want := range tests {
+		if got := GitRefKind(ref); got != want {
+			t.Errorf("GitRefKind(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestParseGitRef(t *testing.T) {
+	t.Parallel()
+
+	tests := []testParse{
+		{
+			"github.com/user/repo@main", map[string]string{
+				"ref":     "main",
+				"refkind": "symbolic",
+			},
+		},
+		{
+			"github.com/user/repo@" + testCommit, map[string]string{
+				"ref":     testCommit,
+				"refkind": "sha1",
+			},
+		},
+		{
+			"github.com/user/repo@abcdef0", map[string]string{
+				"refkind": "short",
+			},
+		},
+		{
+			"hg+https://example.com/repo@abcdef0", map[string]string{
+				"refkind": "",
+			},
+		},
+		{
+			"github.com/user/repo", map[string]string{
+				"refkind": "",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", tc.in, err)
+			continue
+		}
+
+		m, _ := got.Map()
+
+		for ke, ve := range tc.out {
+			if va := m[ke]; ve != va {
+				t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+			}
+		}
+	}
+
+	for _, in := range []string{
+		"github.com/user/repo@feat..x",
+		"github.com/user/repo@HEAD~1",
+		"github.com/user/repo@HEAD^",
+		"github.com/user/repo@a:b",
+		"github.com/user/repo@main.lock",
+		"git@github.com:user/repo.git@.hidden",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = expected error", in)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package usl
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Resolve resolves a possibly relative reference against the USL in the way url.URL.ResolveReference does, while
+// respecting repository boundaries.  References which are not explicitly relative (i.e. not starting with "./" or
+// "../") are parsed as is.  Within a repository "./x" yields a deeper InPath at the same Ref, whereas climbing out
+// of the repository root with "../other" yields a sibling repository on the same host.  A trailing "@ref" sets the
+// reference of the result; a bare "@ref" changes only the reference.
+func (us *USL) Resolve(ref string) (*USL, error) {
+	if strings.HasPrefix(ref, "@") {
+		return us.with(us.Name, us.InPath, unescapeAt(ref[1:]))
+	}
+
+	if !isRelative(ref) {
+		return Parse(ref)
+	}
+
+	if us.Class == ociScheme || isObjectStorage(us.Scheme) {
+		return nil, fmt.Errorf("cannot resolve relative reference %q against %s source", ref, us.Scheme)
+	}
+
+	rel, newRef, hasRef := parseRef(ref)
+	rel, newRef = unescapeAt(rel), unescapeAt(newRef)
+
+	if us.Class == "" {
+		if hasRef {
+			return nil, fmt.Errorf("reference found for unclassified source: %q", newRef)
+		}
+
+		switch joined := path.Join(us.BasePath, rel); {
+		case joined == ".." || strings.HasPrefix(joined, "../"):
+			return nil, fmt.Errorf("relative reference %q climbs above the root", rel)
+		case joined == ".":
+			return us.with("", "", "")
+		default:
+			return us.with(joined, "", "")
+		}
+	}
+
+	name, inpath, escaped, err := resolveSegments(splitPath(us.Name), splitPath(us.InPath), rel)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasRef && !escaped {
+		newRef = us.Ref
+	}
+
+	return us.with(name, inpath, newRef)
+}
+
+// WithRef returns a copy of the USL at the given reference, or without a reference if empty.
+func (us *USL) WithRef(ref string) (*USL, error) {
+	if us.Class == "" || us.Class == ociScheme || isObjectStorage(us.Scheme) {
+		return nil, fmt.Errorf("cannot set reference %q of %s source", ref, us.Scheme)
+	}
+
+	return us.with(us.Name, us.InPath, ref)
+}
+
+func isRelative(ref string) bool {
+	for _, prefix := range []string{"./", "../"} {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+
+	return ref == "." || ref == ".."
+}
+
+// resolveSegments applies the relative path to the path made of the repository name and the in repository path.
+// Climbing above the repository root moves to a sibling repository having a name of the same depth.
+func resolveSegments(name, inpath []string, rel string) (string, string, bool, error) {
+	boundary := len(name)
+	stack := append(append([]string{}, name...), inpath...)
+	lowest := len(stack)
+
+	for _, segment := range strings.Split(rel, "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			if len(stack) == 0 {
+				return "", "", false, fmt.Errorf("relative reference %q climbs above the root", rel)
+			}
+
+			stack = stack[:len(stack)-1]
+			if len(stack) < lowest {
+				lowest = len(stack)
+			}
+		default:
+			stack = append(stack, segment)
+		}
+	}
+
+	escaped := lowest < boundary
+
+	if len(stack) < boundary {
+		if escaped && len(stack) > 0 {
+			return strings.Join(stack, "/"), "", true, nil
+		}
+
+		return "", "", false, fmt.Errorf("relative reference %q resolves to an incomplete repository path", rel)
+	}
+
+	return strings.Join(stack[:boundary], "/"), strings.Join(stack[boundary:], "/"), escaped, nil
+}
+
+// with returns a copy of the USL for the given repository name, in repository path and reference.
+func (us *USL) with(name, inpath, ref string) (*USL, error) {
+	n := *us
+
+	n.Name = name
+	n.InPath = inpath
+	n.Ref = ref
+	n.Commit = ""
+	n.Upstream = ""
+
+	if strings.HasPrefix(us.Path, "/") || us.Path == "" {
+		n.Path = "/" + name
+	} else {
+		n.Path = name
+	}
+
+	n.BasePath = relPath(n.Path)
+	n.RefKind = n.refKind()
+	n.computeNames()
+
+	if n.Class != "" && supportedProviders.contains(n.Host) && len(splitPath(name)) < 2 {
+		return nil, fmt.Errorf("incomplete repository path %q for provider %q", name, n.Host)
+	}
+
+	if n.Ref != "" {
+		if err := n.Class.ValidateRef(n.Ref); err != nil {
+			return nil, err
+		}
+	}
+
+	n.Source = n.source()
+	n.ID = n.id()
+
+	return &n, nil
+}
+
+func splitPath(p string) []string {
+	if p = relPath(p); p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
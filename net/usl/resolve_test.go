@@ -0,0 +1,168 @@
+package usl
+
+import (
+	"testing"
+)
+
+type testResolve struct {
+	base string
+	ref  string
+	out  map[string]string
+}
+
+//nolint:funlen
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	tests := []testResolve{
+		{
+			"github.com/user/repo@main", "./sub/dir", map[string]string{
+				"source": "https://github.com/user/repo.git",
+
+				"inpath": "sub/dir",
+				"name":   "user/repo",
+				"ref":    "main",
+			},
+		},
+		{
+			"github.com/user/repo/a/b@main", "../c", map[string]string{
+				"source": "https://github.com/user/repo.git",
+
+				"inpath": "a/c",
+				"ref":    "main",
+			},
+		},
+		{
+			"github.com/user/repo@main", "../sibling-repo", map[string]string{
+				"source": "https://github.com/user/sibling-repo.git",
+
+				"inpath": "",
+				"name":   "user/sibling-repo",
+				"ref":    "",
+			},
+		},
+		{
+			"github.com/user/repo/a@main", "../../other/x@v1", map[string]string{
+				"source": "https://github.com/user/other.git",
+
+				"inpath": "x",
+				"name":   "user/other",
+				"ref":    "v1",
+			},
+		},
+		{
+			"git@github.com:user/repo.git", "../../org/repo", map[string]string{
+				"source": "git@github.com:org/repo.git",
+
+				"name": "org/repo",
+			},
+		},
+		{
+			"github.com/user/repo/a@main", "@next", map[string]string{
+				"inpath": "a",
+				"ref":    "next",
+			},
+		},
+		{
+			"file:///path/to/repo.git", "../other", map[string]string{
+				"source": "file:///path/to/other",
+
+				"name": "path/to/other",
+			},
+		},
+		{
+			"https://example.com/a/b", "./c", map[string]string{
+				"source": "https://example.com/a/b/c",
+			},
+		},
+		{
+			"https://example.com/a/b", "../c", map[string]string{
+				"source": "https://example.com/a/c",
+			},
+		},
+		{
+			"https://example.com/a/b", "../..", map[string]string{
+				"source": "https://example.com",
+			},
+		},
+		{
+			"github.com/user/repo", "gitlab.com/other/repo", map[string]string{
+				"source": "https://gitlab.com/other/repo.git",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		base, err := Parse(tc.base)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", tc.base, err)
+			continue
+		}
+
+		got, err := base.Resolve(tc.ref)
+		if err != nil {
+			t.Errorf("Resolve(%q, %q) = unexpected err %q", tc.base, tc.ref, err)
+			continue
+		}
+
+		m, _ := got.Map()
+
+		for ke, ve := range tc.out {
+			if va, ok := m[ke]; ok {
+				if ve != va {
+					t.Errorf("\t%40s %-12s    %-12s\twant: %-12s\tgot:  %-12s", tc.base, tc.ref, ke, ve, va)
+				}
+			}
+		}
+	}
+}
+
+func TestResolveInvalid(t *testing.T) {
+	t.Parallel()
+
+	for base, ref := range map[string]string{
+		"github.com/user/repo":           "../..",
+		"https://example.com/a":          "./b@v1",
+		"https://example.com/a/b":        "../../..",
+		"oci://ghcr.io/org/image":        "../other",
+		"https://example.com/a.zip":      "@v1",
+		"svn://example.com/repo/trunk@1": "@trunk",
+	} {
+		us, err := Parse(base)
+		if err != nil {
+			t.Errorf("Parse(%q) = unexpected err %q", base, err)
+			continue
+		}
+
+		if _, err := us.Resolve(ref); err == nil {
+			t.Errorf("Resolve(%q, %q) = expected error", base, ref)
+		}
+	}
+}
+
+func TestWithRef(t *testing.T) {
+	t.Parallel()
+
+	us, err := Parse("github.com/user/repo/sub@main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := us.WithRef("0123456789abcdef0123456789abcdef01234567")
+	if err != nil {
+		t.Fatalf("WithRef() = unexpected err %q", err)
+	}
+
+	if got.Ref != "0123456789abcdef0123456789abcdef01234567" || got.RefKind != RefSHA1 || got.InPath != "sub" ||
+		us.Ref != "main" {
+		t.Errorf("WithRef() = %+v", got)
+	}
+
+	if got, err = us.WithRef(""); err != nil || got.Ref != "" || got.ID != "https:%2F%2Fgithub.com%2Fuser%2Frepo.git" {
+		t.Errorf("WithRef(%q) = %+v, %v", "", got, err)
+	}
+
+	if _, err := us.WithRef("a..b"); err == nil {
+		t.Errorf("WithRef(%q) = expected error", "a..b")
+	}
+}
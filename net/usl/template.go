@@ -0,0 +1,102 @@
+package usl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TemplateData is the data of the templates rendered by MapCustom, which has the attributes by their lower case names
+// as in Map (e.g. "{{ .name }}"), the fields by their names (e.g. "{{ .Name }}"), the USL itself as "USL", and the
+// rendered custom variables by their names.  Methods could be called as well, e.g. "{{ (.WithRef "v1").CloneURL
+// "ssh" }}".
+type TemplateData map[string]interface{}
+
+// TemplateData returns the template data of the USL.
+func (us *USL) TemplateData() TemplateData {
+	m, _ := us.Map()
+
+	d := make(TemplateData, 2*len(m)+1)
+
+	for k, v := range m {
+		d[k] = v
+	}
+
+	e := reflect.ValueOf(us).Elem()
+
+	for i := 0; i < e.NumField(); i++ {
+		if e.Field(i).CanInterface() {
+			d[e.Type().Field(i).Name] = e.Field(i).Interface()
+		}
+	}
+
+	d["USL"] = us
+
+	return d
+}
+
+// Parts returns the slash separated segments of Name, e.g. "group", "subgroup" and "repo".
+func (d TemplateData) Parts() []string {
+	return d.usl().Parts()
+}
+
+// WithRef returns the template data of the USL at the given reference; see USL.WithRef.
+func (d TemplateData) WithRef(ref string) (TemplateData, error) {
+	us, err := d.usl().WithRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return us.TemplateData(), nil
+}
+
+// CloneURL returns the source of the repository over the given transport; see USL.CloneURL.
+func (d TemplateData) CloneURL(transport string) (string, error) {
+	return d.usl().CloneURL(transport)
+}
+
+func (d TemplateData) usl() *USL {
+	if us, ok := d["USL"].(*USL); ok {
+		return us
+	}
+
+	return &USL{}
+}
+
+// Parts returns the slash separated segments of Name.
+func (us *USL) Parts() []string {
+	return splitPath(us.Name)
+}
+
+// Transports which clone URLs could be built for
+var cloneTransports = newSupported("https", "http", "ssh", "git")
+
+// CloneURL returns the source of the git repository over the given transport (i.e. "https", "http", "ssh" or "git")
+// without the password.  Switching the transport drops the port and the username, where the username is "git" over
+// ssh for the supported providers.
+func (us *USL) CloneURL(transport string) (string, error) {
+	if us.Class != "git" || us.Scheme == "file" {
+		return "", fmt.Errorf("no clone URL for %s source %q", us.Class, us.Source)
+	}
+
+	if !cloneTransports.contains(transport) {
+		return "", fmt.Errorf("unsupported clone transport %q, should be one of %s", transport,
+			strings.Join(cloneTransports.list, ", "))
+	}
+
+	n := *us
+	n.Password = ""
+
+	if transport != us.Scheme {
+		n.Scheme = transport
+		n.Port = ""
+		n.Host = joinHostPort(n.Domain, "")
+		n.Username = ""
+
+		if transport == "ssh" && supportedProviders.contains(n.Host) {
+			n.Username = "git"
+		}
+	}
+
+	return n.source(), nil
+}
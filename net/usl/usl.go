@@ -2,13 +2,12 @@ package usl
 
 import (
 	"fmt"
-	"net"
 	"net/url"
 	"path/filepath"
-	"reflect"
 	"regexp"
 	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/PuerkitoBio/purell"
 
@@ -29,10 +28,18 @@ var (
 		"ftp",
 		"ftps",
 		"file",
+		"svn",
+		"svn+ssh",
+		"bzr",
+		"bzr+ssh",
+		"oci",
+		"s3",
+		"gs",
+		"az",
 	)
 
 	supportedProviders = newSupported(
-		"bitbucket.com",
+		"bitbucket.org",
 		"github.com",
 		"gitlab.com",
 		"salsa.debian.org",
@@ -40,11 +47,22 @@ var (
 
 	supportedClasses = newSupported(
 		"git",
+		"7z",
+		"bz2",
+		"gz",
+		"lz",
+		"tar",
 		"tar.bz2",
 		"tar.gz",
+		"tar.lz",
 		"tar.xz",
+		"tar.zst",
+		"tbz2",
 		"tgz",
+		"txz",
+		"xz",
 		"zip",
+		"zst",
 	)
 )
 
@@ -74,25 +92,39 @@ func (s *supported) contains(support string) bool {
 
 // USL should be commented
 type USL struct {
-	Class    string // Source class
-	Domain   string // url.URL Host without port
-	Fragment string // url.URL Fragment
-	BasePath string // url.URL Path without leading and trailing slashes
-	Host     string // url.URL Host
-	ID       string // Source identifier
-	InPath   string // Relative path after root source
-	Name     string // Name of the source in relative path form
-	Password string // url.Userinfo Password
-	Path     string // url.URL Port
-	Port     string // url.URL Port
-	Ref      string // Git reference (i.e. branch, tag, commit)
-	Scheme   string // url.URL Scheme
-	Source   string // Transport string
-	Username string // url.Userinfo Username
+	Account  string  // Storage account of Azure objects
+	Bucket   string  // Bucket (or container) of objects
+	Class    Class   // Source class
+	Commit   string  // Current commit of local git working trees
+	Digest   string  // Content digest of OCI artifacts
+	Domain   string  // url.URL Host without port
+	Fragment string  // url.URL Fragment
+	BasePath string  // url.URL Path without leading and trailing slashes
+	Host     string  // url.URL Host
+	ID       string  // Source identifier
+	InPath   string  // Relative path after root source
+	Key      string  // Key of objects
+	Name     string  // Name of the source in relative path form
+	Owner    string  // All but the last segment of Name, i.e. user or (nested) group
+	Password string  // url.Userinfo Password
+	Path     string  // url.URL Path
+	Port     string  // url.URL Port
+	Ref      string  // Version control reference (i.e. branch, tag, commit) or OCI tag
+	RefKind  RefKind // Kind of git references, i.e. symbolic name or object ID
+	Region   string  // Region of objects
+	Repo     string  // Last segment of Name, i.e. short name of the repository
+	Scheme   string  // url.URL Scheme
+	Slug     string  // File name unique for the domain and the name
+	Source   string  // Transport string
+	Upstream string  // Upstream remote USL of local git working trees
+	Username string  // url.Userinfo Username
+	Version  string  // Version (or generation) of objects
+
+	query url.Values
 }
 
 func newFromURL(u *url.URL) *USL {
-	var username, password, domain, port string
+	var username, password string
 
 	if u.User != nil {
 		username = u.User.Username()
@@ -102,102 +134,115 @@ func newFromURL(u *url.URL) *USL {
 		}
 	}
 
-	domain = u.Host
-
-	if u.Host != "" {
-		var err error
-		if domain, port, err = net.SplitHostPort(u.Host); err != nil {
-			domain = u.Host
-			port = ""
-		}
-	}
-
 	return &USL{
-		Domain:   domain,
+		Domain:   u.Hostname(),
 		Fragment: u.Fragment,
 		Host:     u.Host,
 		Password: password,
 		Path:     u.Path,
-		Port:     port,
+		Port:     u.Port(),
 		Scheme:   u.Scheme,
 		Username: username,
+
+		query: u.Query(),
 	}
 }
 
 // Parse should be commented
 func Parse(rawurl string) (*USL, error) {
-	u, err := parse(rawurl)
+	us, err := parseLenient(rawurl, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	us := newFromURL(u)
-	if err = us.compute(); err != nil {
+	if err = us.validate(); err != nil {
 		return nil, err
 	}
 
 	return us, nil
 }
 
-// Parse should be commented
-func ParseMayLocalPath(rawurl string) (*USL, error) {
-	in, err := reduceLocal(rawurl)
+// parseLenient parses the given input without validating the parts which could still be reported by Lint.
+func parseLenient(rawurl string, tr *tracer) (*USL, error) {
+	u, err := parse(rawurl, tr)
 	if err != nil {
 		return nil, err
 	}
 
-	return Parse(in)
-}
+	us := newFromURL(u)
+	if err = us.compute(tr); err != nil {
+		return nil, err
+	}
 
-// Map should be commented
-func (us *USL) Map() (map[string]string, []string) {
-	m := map[string]string{}
+	return us, nil
+}
 
-	e := reflect.ValueOf(us).Elem()
+// Parse should be commented
+func ParseMayLocalPath(rawurl string) (*USL, error) {
+	return DefaultLocalRules.ParseMayLocalPath(rawurl)
+}
 
-	for i := 0; i < e.NumField(); i++ {
-		if e.Field(i).CanInterface() {
-			k := strings.ToLower(e.Type().Field(i).Name)
-			v := reflect.ValueOf(e.Field(i).Interface()).String()
+// Map returns the values of the attributes (see Attributes) along with their names in order.
+func (us *USL) Map() (map[string]string, []string) {
+	m := make(map[string]string, len(attributes))
+	ks := make([]string, 0, len(attributes))
 
-			m[k] = v
-		}
+	for _, a := range attributes {
+		m[a.Name] = a.Get(us)
+		ks = append(ks, a.Name)
 	}
 
-	ks := make([]string, 0, len(m))
-
-	for k := range m {
-		ks = append(ks, k)
-	}
+	return m, ks
+}
 
-	sort.Strings(ks)
+// MapCustom returns the attributes of Map along with the custom variables rendered from the given templates named
+// after the variables.  Templates are rendered against TemplateData, so they could refer to the attributes, fields
+// and methods of the USL as well as to the other custom variables, and use the functions of textutil.FuncMap
+// extended by the given functions; see textutil.RenderData.  A textutil.TemplateError is returned for invalid
+// templates.
+func (us *USL) MapCustom(
+	templateMap map[string]string, funcs ...template.FuncMap,
+) (map[string]string, []string, error) {
+	return us.mapCustom(templateMap, func(data TemplateData) error {
+		return textutil.RenderData(templateMap, data, funcs...)
+	})
+}
 
-	return m, ks
+// MapSandboxed is like MapCustom but renders the untrusted templates in the given sandbox.
+func (us *USL) MapSandboxed(
+	sb *textutil.Sandbox, templateMap map[string]string, funcs ...template.FuncMap,
+) (map[string]string, []string, error) {
+	return us.mapCustom(templateMap, func(data TemplateData) error {
+		return sb.RenderData(templateMap, data, funcs...)
+	})
 }
 
-// MapCustom should be commented
-func (us *USL) MapCustom(templateMap map[string]string) (map[string]string, []string) {
+func (us *USL) mapCustom(
+	templateMap map[string]string, render func(data TemplateData) error,
+) (map[string]string, []string, error) {
 	m, ks := us.Map()
+	data := us.TemplateData()
 
-	for k, v := range templateMap {
-		m[k] = textutil.RenderString(v, m)
+	if err := render(data); err != nil {
+		return nil, nil, err
+	}
+
+	for k := range templateMap {
+		if _, ok := m[k]; !ok {
+			ks = append(ks, k)
+		}
 
-		ks = append(ks, k)
+		m[k], _ = data[k].(string)
 	}
 
 	sort.Strings(ks)
 
-	return m, ks
+	return m, ks, nil
 }
 
+// IsLocal reports whether the given input is a local path on the running platform.
 func IsLocal(in string) bool {
-	for _, p := range []string{"/", "./", "../"} {
-		if strings.HasPrefix(in, p) {
-			return true
-		}
-	}
-
-	return false
+	return DefaultLocalRules.IsLocal(in)
 }
 
 func (us *USL) String() string {
@@ -206,21 +251,60 @@ func (us *USL) String() string {
 
 // Private methods
 
-func (us *USL) compute() error {
-	if strings.HasSuffix(us.Scheme, "+ssh") {
-		us.Scheme = "ssh" //nolint:goconst
+func (us *USL) compute(tr *tracer) error { //nolint:funlen,gocognit
+	if us.Scheme == "file" && isDriveHost(us.Host) {
+		us.Path = "/" + strings.ToUpper(us.Host) + us.Path
+		us.Host, us.Domain, us.Port = "", "", ""
+		tr.add("drive", true, "host is a windows drive, moved into path %q", us.Path)
+	}
+
+	host := us.Host
+	if err := us.normalizeHost(); err != nil {
+		return err
+	}
+
+	tr.add("normalizeHost", host != us.Host, "host %q", us.Host)
+
+	if us.Scheme == ociScheme {
+		tr.add("oci", true, "oci artifact")
+
+		return us.computeOCI()
+	}
+
+	if us.fromObjectStorageURL() || isObjectStorage(us.Scheme) {
+		tr.add("objectStorage", true, "%s object", us.Scheme)
+
+		return us.computeObject()
+	}
+
+	if class, transport, ok := vcsScheme(us.Scheme); ok {
+		us.Class = class
+		us.Scheme = transport
+		tr.add("vcsScheme", true, "class %q from scheme with transport %q", class, transport)
 	}
 
 	if path, ref, ok := parseRef(us.Path); ok {
 		us.Path = path
-		us.Ref = ref
+		us.Ref = unescapeAt(ref)
+		tr.add("parseRef", true, "reference %q after the last '@'", us.Ref)
+	} else {
+		tr.add("parseRef", false, "no '@' in path")
 	}
 
-	if before, class, after, ok := parseClass(us.Path); ok && supportedClasses.contains(class) {
+	us.Path = unescapeAt(us.Path)
+
+	// Suffixes of other classes are ignored for version control systems known from the scheme, e.g. "hg+https"
+	if before, class, after, ok := parseClass(us.Path); ok && supportedClasses.contains(class) &&
+		(!us.Class.IsVCS() || Class(class) == us.Class) {
 		us.Path = before
 		us.Name = relPath(before)
 		us.InPath = relPath(after)
-		us.Class = class
+		us.Class = Class(class)
+		tr.add("parseClass", true, "class %q from suffix, name %q and in path %q", class, us.Name, us.InPath)
+	} else if us.Class.IsVCS() {
+		tr.add("parseClass", false, "class %q already known", us.Class)
+	} else {
+		tr.add("parseClass", false, "no supported class suffix in path %q", us.Path)
 	}
 
 	us.BasePath = relPath(us.Path)
@@ -228,6 +312,7 @@ func (us *USL) compute() error {
 	if supportedProviders.contains(us.Host) {
 		if us.Class == "" {
 			us.Class = "git" //nolint:goconst
+			tr.add("provider", true, "class %q by default for provider %q", us.Class, us.Host)
 		}
 
 		if us.Name == "" {
@@ -238,6 +323,7 @@ func (us *USL) compute() error {
 
 			us.Name = strings.Join(parts[:2], "/")
 			us.InPath = strings.Join(parts[2:], "/")
+			tr.add("provider", true, "name %q and in path %q from the first two segments", us.Name, us.InPath)
 		}
 	}
 
@@ -245,73 +331,89 @@ func (us *USL) compute() error {
 		us.Name = us.BasePath
 	}
 
-	if us.Ref != "" && us.Class != "git" {
-		return fmt.Errorf("reference found for non git source: %q", us.Ref)
-	}
-
+	us.RefKind = us.refKind()
+	us.computeNames()
 	us.Source = us.source()
 	us.ID = us.id()
 
 	return nil
 }
 
-func (us *USL) id() string {
-	s := us.Source
+// computeNames computes the attributes derived from Name.  Unclassified sources have no owner or repository, and
+// local sources have no owner.
+func (us *USL) computeNames() {
+	us.Owner, us.Repo, us.Slug = "", "", ""
 
-	if us.Ref != "" {
-		var buf strings.Builder
+	if us.Name == "" {
+		return
+	}
 
-		buf.WriteString(s)
-		buf.WriteByte('@')
-		buf.WriteString(us.Ref)
+	domain := us.Domain
+	if isObjectStorage(us.Scheme) {
+		domain = us.Scheme
+	}
 
-		s = buf.String()
+	us.Slug = slug(domain, us.Name)
+
+	if us.Class == "" {
+		return
 	}
 
-	return url.PathEscape(s)
+	parts := splitPath(us.Name)
+	us.Repo = parts[len(parts)-1]
+
+	if us.Scheme != "file" {
+		us.Owner = strings.Join(parts[:len(parts)-1], "/")
+	}
 }
 
-func reduceLocal(rawurl string) (string, error) {
-	if !IsLocal(rawurl) {
-		return rawurl, nil
+// validate checks the parts of the USL which are computed leniently.
+func (us *USL) validate() error {
+	if us.Ref != "" {
+		return us.Class.ValidateRef(us.Ref)
 	}
 
-	var buf strings.Builder
+	return nil
+}
 
-	buf.WriteString("file://")
+func (us *USL) id() string {
+	s := us.Source
 
-	abspath, err := filepath.Abs(rawurl)
-	if err != nil {
-		return "", err
-	}
+	if us.Ref != "" {
+		var buf strings.Builder
+
+		buf.WriteString(s)
+		buf.WriteByte('@')
+		buf.WriteString(escapeAt(us.Ref))
 
-	buf.WriteString(abspath)
+		s = buf.String()
+	}
 
-	return buf.String(), nil
+	return url.PathEscape(s)
 }
 
 func (us *USL) source() string { //nolint:funlen
 	var buf strings.Builder
 
 	if us.Scheme == "file" {
-		buf.WriteString(us.Scheme)
+		buf.WriteString(vcsSchemeOf(us.Class, us.Scheme))
 		buf.WriteString("://")
 
 		if us.Host != "" {
-			buf.WriteString(us.Host)
+			buf.WriteString(us.urlHost())
 		}
 
-		buf.WriteString(us.Path)
+		buf.WriteString(strings.ReplaceAll((&url.URL{Path: us.Path}).EscapedPath(), "@", "%40"))
 
-		if us.Class != "" && us.Class != "git" {
+		if us.Class != "" && !us.Class.IsVCS() {
 			buf.WriteByte('.')
-			buf.WriteString(us.Class)
+			buf.WriteString(us.Class.String())
 		}
 
 		return buf.String()
 	}
 
-	if us.Scheme == "ssh" && us.Port == "" { //nolint:goconst
+	if us.Scheme == "ssh" && us.Port == "" && !us.isForeignVCS() { //nolint:goconst
 		if us.Username != "" {
 			buf.WriteString(us.Username)
 			buf.WriteByte('@')
@@ -319,49 +421,100 @@ func (us *USL) source() string { //nolint:funlen
 
 		buf.WriteString(us.Host)
 		buf.WriteByte(':')
-		buf.WriteString(us.Name)
+		buf.WriteString(escapeAt(us.Name))
 
 		if us.Class != "" {
 			buf.WriteByte('.')
-			buf.WriteString(us.Class)
+			buf.WriteString(us.Class.String())
 		}
 
 		return buf.String()
 	}
 
-	buf.WriteString(us.Scheme)
+	buf.WriteString(vcsSchemeOf(us.Class, us.Scheme))
 	buf.WriteString("://")
 
 	if us.Username != "" {
-		buf.WriteString(us.Username)
-
 		if us.Password != "" {
-			buf.WriteByte(':')
-			buf.WriteString(us.Password)
+			buf.WriteString(url.UserPassword(us.Username, us.Password).String())
+		} else {
+			buf.WriteString(url.User(us.Username).String())
 		}
 
 		buf.WriteByte('@')
 	}
 
-	buf.WriteString(us.Host)
+	buf.WriteString(us.urlHost())
 
-	if us.Class == "" {
+	switch {
+	case us.Class == "":
 		if us.BasePath != "" {
 			buf.WriteByte('/')
-			buf.WriteString(us.BasePath)
+			buf.WriteString(escapeAt(us.BasePath))
 		}
-	} else {
+	case us.isForeignVCS():
+		if us.Name != "" {
+			buf.WriteByte('/')
+			buf.WriteString(escapeAt(us.Name))
+		}
+	default:
 		buf.WriteByte('/')
-		buf.WriteString(us.Name)
+		buf.WriteString(escapeAt(us.Name))
 		buf.WriteByte('.')
-		buf.WriteString(us.Class)
+		buf.WriteString(us.Class.String())
 	}
 
 	return buf.String()
 }
 
+// isForeignVCS reports whether the source is managed by a version control system other than git, which have no
+// scp-like syntax and no class suffix convention.
+func (us *USL) isForeignVCS() bool {
+	return us.Class.IsVCS() && us.Class != "git"
+}
+
 // Helpers
 
+// slug returns a file name for the given domain and name, e.g. "github.com-user-repo", where the input is lower cased
+// and the runs of characters other than ASCII letters, digits and dots are turned into "-".  Since this is ambiguous
+// unless the input consists of only the kept characters and single slashes, a short hash of the input is appended
+// otherwise, e.g. "github.com-user-my-repo-1a2b3c4d".
+func slug(domain, name string) string {
+	in := strings.Trim(domain+"/"+name, "/")
+
+	var buf strings.Builder
+
+	lower := strings.ToLower(in)
+	lossy, dash := lower != in || strings.Contains(in, "//"), false
+
+	for _, r := range lower {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' {
+			if dash && buf.Len() > 0 {
+				buf.WriteByte('-')
+			}
+
+			buf.WriteRune(r)
+
+			dash = false
+
+			continue
+		}
+
+		lossy = lossy || r != '/'
+		dash = true
+	}
+
+	if lossy {
+		if buf.Len() > 0 {
+			buf.WriteByte('-')
+		}
+
+		buf.WriteString(textutil.ShortHash(in))
+	}
+
+	return buf.String()
+}
+
 func cut(s string, c string) (string, string) {
 	i := strings.Index(s, c)
 
@@ -382,6 +535,18 @@ func groupPatternFromSlice(group string, ss []string) string {
 	return `(?P<` + group + `>` + strings.Join(escaped, "|") + `)`
 }
 
+// longestFirst returns a copy of the given strings sorted by length in descending order, so that alternations in
+// patterns built from them prefer the longest match (e.g. "tar.zst" over "tar").
+func longestFirst(ss []string) []string {
+	sorted := append([]string(nil), ss...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	return sorted
+}
+
 func namedMatches(re *regexp.Regexp, in string) (map[string]string, bool) {
 	match := re.FindStringSubmatch(in)
 
@@ -401,7 +566,7 @@ func namedMatches(re *regexp.Regexp, in string) (map[string]string, bool) {
 }
 
 var reClass = regexp.MustCompile(
-	`^(?P<before>.*?)[.]` + groupPatternFromSlice("class", supportedClasses.list) + `(?P<after>/.*)?$`,
+	`^(?P<before>.*?)[.]` + groupPatternFromSlice("class", longestFirst(supportedClasses.list)) + `(?P<after>/.*)?$`,
 )
 
 func parseClass(path string) (string, string, string, bool) {
@@ -414,6 +579,46 @@ func parseClass(path string) (string, string, string, bool) {
 
 var reRef = regexp.MustCompile(`^(?P<before>.+)@(?P<ref>[^@]*)$`)
 
+// References are separated by the last '@', hence a literal '@' in paths and references is percent encoded as "%40"
+// along with a literal '%' as "%25" to keep the encoding reversible; e.g. "example.com/%40scope/pkg.git@r%402024"
+// has the path "/@scope/pkg.git" and the reference "r@2024".
+var (
+	atEscaper   = strings.NewReplacer("%", "%25", "@", "%40")
+	atUnescaper = strings.NewReplacer("%25", "%", "%40", "@")
+
+	// Used to keep the escapes above from being decoded during URL parsing
+	atProtector = strings.NewReplacer("%25", "%2525", "%40", "%2540")
+)
+
+func escapeAt(s string) string {
+	return atEscaper.Replace(s)
+}
+
+func unescapeAt(s string) string {
+	return atUnescaper.Replace(s)
+}
+
+// protectAt protects the '@' and '%' escapes in the path of the given URL from being decoded, so that references
+// could be split on the literal '@' afterwards; see unescapeAt.
+func protectAt(in string) string {
+	_, rest := cut(in, "://")
+	start := len(in) - len(rest)
+
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return in
+	}
+
+	start += i
+	end := len(in)
+
+	if j := strings.IndexAny(in[start:], "?#"); j >= 0 {
+		end = start + j
+	}
+
+	return in[:start] + atProtector.Replace(in[start:end]) + in[end:]
+}
+
 func parseRef(path string) (string, string, bool) {
 	if m, ok := namedMatches(reRef, path); ok {
 		return m["before"], m["ref"], true
@@ -422,36 +627,77 @@ func parseRef(path string) (string, string, bool) {
 	return path, "", false
 }
 
-func parse(rawurl string) (*url.URL, error) {
+func parse(rawurl string, tr *tracer) (*url.URL, error) { //nolint:funlen
 	in := rawurl
 
 	if IsLocal(in) {
+		tr.add("local", true, "local path not allowed")
+
 		return nil, fmt.Errorf("local file paths not allowed %q", rawurl)
 	}
 
+	tr.add("local", false, "not a local path")
+
 	if scheme, remaining := cut(in, "://"); remaining == "" {
+		tr.add("scheme", false, "no scheme found")
+
+		if in = normalizeLeadingHost(in); in != rawurl {
+			tr.add("normalizeLeadingHost", true, "normalized to %q", in)
+		}
+
 		if m, ok := matchSpecial(in); ok {
+			tr.add("matchSpecial", true, "provider %q with separator %q", m["provider"], m["sep"])
+
 			return parseSpecial(in, m)
 		}
 
+		tr.add("matchSpecial", false, "no supported provider prefix")
+
 		if m, ok := matchSSH(in); ok {
+			tr.add("matchSSH", true, "scp-like syntax with user %q, host %q and path %q", m["user"], m["host"], m["path"])
+
 			return parseSSH(in, m)
 		}
 
+		tr.add("matchSSH", false, "no scp-like \"[user@]host:path\" syntax")
+
+		if m, ok := matchOCI(in); ok {
+			tr.add("matchOCI", true, "registry %q", m["host"])
+
+			return parseOCI(in, m)
+		}
+
+		tr.add("matchOCI", false, "no known registry")
+
 		in = fallbackScheme + "://" + in
+		tr.add("fallback", true, "assumed scheme %q", fallbackScheme)
 	} else {
 		scheme = strings.ToLower(scheme)
+		tr.add("scheme", true, "scheme %q", scheme)
+
+		if scheme == ociScheme {
+			return parseOCI(remaining, nil)
+		}
+
+		if _, transport, ok := vcsScheme(scheme); ok {
+			scheme = transport
+		}
 
 		if !supportedSchemes.contains(scheme) {
 			return nil, fmt.Errorf("unsupported scheme %q", scheme)
 		}
 	}
 
-	return parseUsual(in, nil)
+	u, err := parseUsual(in, nil)
+	if err == nil {
+		tr.add("normalize", true, "%s into %q", normalizeFlagNames, u)
+	}
+
+	return u, err
 }
 
 var reSSH = regexp.MustCompile(
-	`^((?P<user>[a-zA-Z0-9_]+)@)?(?P<host>[a-zA-Z0-9._-]+):(?P<path>.*)$`,
+	`^((?P<user>[a-zA-Z0-9_]+)@)?(?P<host>[a-zA-Z0-9._-]+|` + ipv6Pattern + `):(?P<path>.*)$`,
 )
 
 func matchSSH(in string) (map[string]string, bool) {
@@ -501,15 +747,32 @@ func parseSpecial(_ string, match map[string]string) (*url.URL, error) {
 	}, nil
 }
 
+// URL normalization flags along with their names to explain
+const (
+	normalizeFlags     = purell.FlagsUsuallySafeGreedy | purell.FlagRemoveDuplicateSlashes | purell.FlagRemoveFragment
+	normalizeFlagNames = "FlagsUsuallySafeGreedy|FlagRemoveDuplicateSlashes|FlagRemoveFragment"
+)
+
 func parseUsual(in string, _ map[string]string) (*url.URL, error) {
-	normurl, err := purell.NormalizeURLString(
-		in, purell.FlagsUsuallySafeGreedy|purell.FlagRemoveDuplicateSlashes|purell.FlagRemoveFragment,
-	)
+	// Normalization mangles the zone identifier of IPv6 literals, hence it is removed beforehand and restored later
+	in, zone := splitZone(in)
+	in = protectAt(in)
+
+	normurl, err := purell.NormalizeURLString(in, normalizeFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(normurl)
 	if err != nil {
 		return nil, err
 	}
 
-	return url.Parse(normurl)
+	if zone != "" {
+		u.Host = joinHostPort(u.Hostname()+"%"+zone, u.Port())
+	}
+
+	return u, nil
 }
 
 func relPath(path string) string {
@@ -0,0 +1,273 @@
+package usl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitDir         = ".git"
+	gitRefPrefix   = "ref: "
+	gitHeadsPrefix = "refs/heads/"
+	gitDirPrefix   = "gitdir: "
+	gitDefRemote   = "origin"
+)
+
+// worktree is a git working tree found by inspecting the file system without the git binary.
+type worktree struct {
+	root   string // Root directory of the working tree
+	gitDir string // Git directory holding HEAD (differs from common for linked working trees)
+	common string // Git directory holding config and refs
+	head   string // Contents of HEAD
+}
+
+// inspectWorkTree fills in the class, reference and paths of a file USL pointing inside a git working tree.  It
+// leaves the USL intact if no working tree is found.
+func (r *LocalRules) inspectWorkTree(us *USL) error {
+	if us.Scheme != "file" || us.Host != "" || us.Class != "" {
+		return nil
+	}
+
+	dir := us.Path
+	if r.Windows && reDrive.MatchString(strings.TrimPrefix(dir, "/")) {
+		dir = strings.TrimPrefix(dir, "/")
+	}
+
+	wt, err := findWorkTree(filepath.FromSlash(dir))
+	if err != nil || wt == nil {
+		return err
+	}
+
+	root := filepath.ToSlash(wt.root)
+	if !strings.HasPrefix(root, "/") {
+		root = "/" + root
+	}
+
+	rel, err := filepath.Rel(wt.root, filepath.FromSlash(dir))
+	if err != nil {
+		return err
+	}
+
+	if rel = filepath.ToSlash(rel); rel == "." {
+		rel = ""
+	}
+
+	branch, commit := wt.resolveHead()
+
+	us.Class = "git"
+	us.Path = path.Clean(root)
+	us.BasePath = relPath(us.Path)
+	us.Name = us.BasePath
+	us.InPath = rel
+	us.Commit = commit
+
+	if us.Ref = branch; us.Ref == "" {
+		us.Ref = commit
+	}
+
+	if r.Upstream {
+		us.Upstream = wt.upstream(branch)
+	}
+
+	us.RefKind = us.refKind()
+	us.computeNames()
+	us.Source = us.source()
+	us.ID = us.id()
+
+	return nil
+}
+
+func findWorkTree(dir string) (*worktree, error) {
+	if fi, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	for {
+		dotgit := filepath.Join(dir, gitDir)
+
+		if fi, err := os.Stat(dotgit); err == nil {
+			wt := &worktree{root: dir, gitDir: dotgit, common: dotgit}
+
+			if !fi.IsDir() {
+				if err := wt.followGitFile(dotgit); err != nil {
+					return nil, err
+				}
+			}
+
+			head, err := ioutil.ReadFile(filepath.Join(wt.gitDir, "HEAD"))
+			if err != nil {
+				return nil, err
+			}
+
+			wt.head = strings.TrimSpace(string(head))
+
+			return wt, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+
+		dir = parent
+	}
+}
+
+// followGitFile follows the ".git" file of linked working trees and submodules to the actual git directory.
+func (wt *worktree) followGitFile(dotgit string) error {
+	content, err := ioutil.ReadFile(dotgit)
+	if err != nil {
+		return err
+	}
+
+	s := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(s, gitDirPrefix) {
+		return fmt.Errorf("invalid git file %q", dotgit)
+	}
+
+	wt.gitDir = strings.TrimPrefix(s, gitDirPrefix)
+	if !filepath.IsAbs(wt.gitDir) {
+		wt.gitDir = filepath.Join(wt.root, wt.gitDir)
+	}
+
+	wt.common = wt.gitDir
+
+	if content, err := ioutil.ReadFile(filepath.Join(wt.gitDir, "commondir")); err == nil {
+		wt.common = strings.TrimSpace(string(content))
+		if !filepath.IsAbs(wt.common) {
+			wt.common = filepath.Join(wt.gitDir, wt.common)
+		}
+	}
+
+	return nil
+}
+
+// resolveHead returns the current branch, which is empty for a detached HEAD, and the current commit, which is empty
+// for an unborn branch.
+func (wt *worktree) resolveHead() (string, string) {
+	if !strings.HasPrefix(wt.head, gitRefPrefix) {
+		return "", wt.head
+	}
+
+	ref := strings.TrimPrefix(wt.head, gitRefPrefix)
+
+	return strings.TrimPrefix(ref, gitHeadsPrefix), wt.resolveRef(ref)
+}
+
+// resolveRef returns the object name of the given full reference by looking at the loose and packed references.
+func (wt *worktree) resolveRef(ref string) string {
+	for _, dir := range []string{wt.gitDir, wt.common} {
+		if content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(content))
+		}
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(wt.common, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+
+		if object, name := cut(line, " "); name == ref {
+			return object
+		}
+	}
+
+	return ""
+}
+
+// upstream returns the USL of the remote branch the given branch tracks, or of the default remote.
+func (wt *worktree) upstream(branch string) string {
+	content, err := ioutil.ReadFile(filepath.Join(wt.common, "config"))
+	if err != nil {
+		return ""
+	}
+
+	config := parseGitConfig(string(content))
+
+	remote, merge := gitDefRemote, ""
+	if branch != "" {
+		if r := config["branch."+branch+".remote"]; r != "" {
+			remote = r
+		}
+
+		merge = strings.TrimPrefix(config["branch."+branch+".merge"], gitHeadsPrefix)
+	}
+
+	rawurl := config["remote."+remote+".url"]
+	if rawurl == "" {
+		return ""
+	}
+
+	if !filepath.IsAbs(rawurl) && IsLocal(rawurl) {
+		rawurl = filepath.Join(wt.root, rawurl)
+	}
+
+	us, err := ParseMayLocalPath(rawurl)
+	if err != nil {
+		return ""
+	}
+
+	if merge != "" && us.Class == "git" {
+		return us.Source + "@" + escapeAt(merge)
+	}
+
+	return us.Source
+}
+
+// parseGitConfig parses a git configuration into a map keyed by "section.subsection.key" in the way git does for
+// the subset of the syntax needed here; section and key names are case insensitive while subsections are not.
+func parseGitConfig(content string) map[string]string {
+	config := map[string]string{}
+	section := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			header := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			name, sub := cut(header, " ")
+			section = strings.ToLower(name)
+
+			if sub = strings.Trim(strings.TrimSpace(sub), `"`); sub != "" {
+				section += "." + sub
+			}
+
+			continue
+		}
+
+		key, value := cut(line, "=")
+		value = strings.TrimSpace(value)
+
+		if i := strings.IndexAny(value, "#;"); i >= 0 && !strings.HasPrefix(value, `"`) {
+			value = strings.TrimSpace(value[:i])
+		}
+
+		config[section+"."+strings.ToLower(strings.TrimSpace(key))] = strings.Trim(value, `"`)
+	}
+
+	return config
+}
@@ -0,0 +1,185 @@
+package usl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testCommit       = "0123456789abcdef0123456789abcdef01234567"
+	testPackedCommit = "89abcdef0123456789abcdef0123456789abcdef"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//nolint:funlen
+func TestWorkTree(t *testing.T) {
+	t.Parallel()
+
+	tmp, err := ioutil.TempDir("", "usl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	packedRefs := "# pack-refs with: peeled fully-peeled sorted\n" + testPackedCommit + " refs/heads/packed\n"
+
+	writeTestFiles(t, tmp, map[string]string{
+		"repo/.git/HEAD":                       "ref: refs/heads/main\n",
+		"repo/.git/refs/heads/main":            testCommit + "\n",
+		"repo/.git/packed-refs":                packedRefs,
+		"repo/a/b/file.txt":                    "",
+		"detached/.git/HEAD":                   testCommit + "\n",
+		"packed/.git/HEAD":                     "ref: refs/heads/packed\n",
+		"packed/.git/packed-refs":              testPackedCommit + " refs/heads/packed\n",
+		"linked/.git":                          "gitdir: ../repo/.git/worktrees/linked\n",
+		"repo/.git/worktrees/linked/HEAD":      "ref: refs/heads/feature/x\n",
+		"repo/.git/worktrees/linked/commondir": "../..\n",
+		"repo/.git/refs/heads/feature/x":       testPackedCommit + "\n",
+		"repo/.git/config": `[core]
+	bare = false
+[remote "origin"]
+	url = git@github.com:user/repo.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[remote "fork"]
+	url = https://example.com/fork/repo.git ; a comment
+[branch "main"]
+	remote = origin
+	merge = refs/heads/main
+[branch "feature/x"]
+	remote = fork
+	merge = refs/heads/x
+`,
+		"packed/.git/config": `[remote "origin"]
+	url = https://example.com/packed.git
+[branch "packed"]
+	remote = origin
+	merge = refs/heads/release@2024
+`,
+		"plain/file.txt": "",
+	})
+
+	rules := &LocalRules{
+		Home:     os.UserHomeDir,
+		Getwd:    func() (string, error) { return tmp, nil },
+		Git:      true,
+		Upstream: true,
+	}
+
+	root := strings.TrimPrefix(filepath.ToSlash(tmp), "/")
+
+	tests := []testParse{
+		{
+			"./repo/a/b", map[string]string{
+				"source": "file:///" + root + "/repo",
+
+				"class":    "git",
+				"commit":   testCommit,
+				"inpath":   "a/b",
+				"name":     root + "/repo",
+				"ref":      "main",
+				"upstream": "git@github.com:user/repo.git@main",
+			},
+		},
+		{
+			"./repo/a/b/file.txt", map[string]string{
+				"class":  "git",
+				"inpath": "a/b/file.txt",
+			},
+		},
+		{
+			"./repo", map[string]string{
+				"class":  "git",
+				"inpath": "",
+				"path":   "/" + root + "/repo",
+			},
+		},
+		{
+			"./detached", map[string]string{
+				"class":    "git",
+				"commit":   testCommit,
+				"ref":      testCommit,
+				"upstream": "",
+			},
+		},
+		{
+			"./packed", map[string]string{
+				"commit":   testPackedCommit,
+				"ref":      "packed",
+				"upstream": "https://example.com/packed.git@release%402024",
+			},
+		},
+		{
+			"./linked", map[string]string{
+				"source": "file:///" + root + "/linked",
+
+				"commit":   testPackedCommit,
+				"ref":      "feature/x",
+				"upstream": "https://example.com/fork/repo.git@x",
+			},
+		},
+		{
+			"./plain", map[string]string{
+				"source": "file:///" + root + "/plain",
+
+				"class": "",
+				"ref":   "",
+			},
+		},
+		{
+			"./missing", map[string]string{
+				"source": "file:///" + root + "/missing",
+
+				"class": "",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := rules.ParseMayLocalPath(tc.in)
+
+		if err != nil {
+			t.Errorf("ParseMayLocalPath(%q) = unexpected err %q", tc.in, err)
+			continue
+		}
+
+		m, _ := got.Map()
+
+		for ke, ve := range tc.out {
+			if va, ok := m[ke]; ok {
+				if ve != va {
+					t.Errorf("\t%40s    %-12s\twant: %-12s\tgot:  %-12s", tc.in, ke, ve, va)
+				}
+			}
+		}
+
+		// Explaining agrees with parsing
+		us, steps, err := rules.ParseExplainMayLocalPath(tc.in)
+		if err != nil || !reflect.DeepEqual(us, got) {
+			t.Errorf("ParseExplainMayLocalPath(%q) = %+v, %v, want %+v", tc.in, us, err, got)
+			continue
+		}
+
+		if first, last := steps[0], steps[len(steps)-1]; !first.Matched || last.Matched != (got.Class == "git") {
+			t.Errorf("ParseExplainMayLocalPath(%q) = steps %q", tc.in, steps)
+		}
+	}
+}
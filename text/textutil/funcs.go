@@ -0,0 +1,209 @@
+package textutil
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Length of the hashes returned by shorthash
+const shortHashLen = 8
+
+// FuncMap returns the functions available in templates.  Functions taking the piped value take it as the last
+// argument, e.g. "{{ .name | replace "/" "-" }}".
+//
+// Strings:
+//
+//	lower S                    Lower case of S
+//	upper S                    Upper case of S
+//	replace OLD NEW S          S with all OLD replaced by NEW
+//	trimPrefix PREFIX S        S without the leading PREFIX
+//	trimSuffix SUFFIX S        S without the trailing SUFFIX
+//	split SEP S                Substrings of S separated by SEP
+//	join SEP LIST              Elements of LIST concatenated with SEP
+//	regexReplace RE REPL S     S with all matches of RE replaced by REPL which could refer to groups, e.g. "${1}"
+//	default DEFAULT S          DEFAULT if S is empty or missing, otherwise S
+//	coalesce S...              First non empty argument, or an empty string
+//
+// Paths:
+//
+//	base P                     Last element of the slash separated path P
+//	dir P                      All but the last element of the slash separated path P
+//	pathescape S               S escaped to be placed inside a URL path segment
+//	slug S                     Lower case S with the runs of non alphanumerics turned into "-", e.g. "user-repo"
+//	sanitize S                 S with the characters invalid in file names turned into "_"
+//	pwd                        Current working directory
+//
+// Hashing:
+//
+//	sha1 S                     Hexadecimal SHA-1 digest of S
+//	sha256 S                   Hexadecimal SHA-256 digest of S
+//	shorthash S                First 8 hexadecimal digits of the SHA-256 digest of S, e.g. for directory names
+//
+// Environment:
+//
+//	env NAME                   Value of the environment variable NAME which fails unless allowed; see Env
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"lower":        strings.ToLower,
+		"upper":        strings.ToUpper,
+		"replace":      replace,
+		"trimPrefix":   trimPrefix,
+		"trimSuffix":   trimSuffix,
+		"split":        split,
+		"join":         join,
+		"regexReplace": regexReplace,
+		"default":      defaultValue,
+		"coalesce":     coalesce,
+
+		"base":       path.Base,
+		"dir":        path.Dir,
+		"pathescape": url.PathEscape,
+		"slug":       Slug,
+		"sanitize":   Sanitize,
+		"pwd":        os.Getwd,
+
+		"sha1":      sha1Hex,
+		"sha256":    sha256Hex,
+		"shorthash": ShortHash,
+
+		"env": Env(),
+	}
+}
+
+// Env returns the env template function which looks up only the given environment variables.
+func Env(allowed ...string) func(name string) (string, error) {
+	allow := map[string]bool{}
+	for _, name := range allowed {
+		allow[name] = true
+	}
+
+	return func(name string) (string, error) {
+		if !allow[name] {
+			return "", fmt.Errorf("environment variable %q not allowed", name)
+		}
+
+		return os.Getenv(name), nil
+	}
+}
+
+// Slug returns the lower case form of the given string where the runs of characters other than letters and digits
+// are turned into a single "-", e.g. "User/My_Repo.git" into "user-my-repo-git".
+func Slug(s string) string {
+	var buf strings.Builder
+
+	dash := false
+
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && buf.Len() > 0 {
+				buf.WriteByte('-')
+			}
+
+			buf.WriteRune(r)
+
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	return buf.String()
+}
+
+// Sanitize returns the given string as a file name valid on all common platforms, i.e. the path separators, the
+// characters reserved on Windows and the control characters are turned into "_", and the trailing dots and spaces
+// are removed.
+func Sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+
+		return r
+	}, s)
+
+	s = strings.TrimRight(s, ". ")
+	if s == "" {
+		return "_"
+	}
+
+	return s
+}
+
+// ShortHash returns the first 8 hexadecimal digits of the SHA-256 digest of the given string.
+func ShortHash(s string) string {
+	return sha256Hex(s)[:shortHashLen]
+}
+
+func replace(old, new, s string) string {
+	return strings.ReplaceAll(s, old, new)
+}
+
+func trimPrefix(prefix, s string) string {
+	return strings.TrimPrefix(s, prefix)
+}
+
+func trimSuffix(suffix, s string) string {
+	return strings.TrimSuffix(s, suffix)
+}
+
+func split(sep, s string) []string {
+	return strings.Split(s, sep)
+}
+
+func join(sep string, elems []string) string {
+	return strings.Join(elems, sep)
+}
+
+func regexReplace(pattern, repl, s string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	return re.ReplaceAllString(s, repl), nil
+}
+
+// Missing values (e.g. absent map keys) are passed as nil to the functions below
+func isEmpty(v interface{}) bool {
+	return v == nil || v == ""
+}
+
+func defaultValue(def, v interface{}) interface{} {
+	if isEmpty(v) {
+		return def
+	}
+
+	return v
+}
+
+func coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !isEmpty(v) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s)) //nolint:gosec
+
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+
+	return hex.EncodeToString(sum[:])
+}
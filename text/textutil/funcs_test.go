@@ -0,0 +1,121 @@
+package textutil
+
+import (
+	"os"
+	"testing"
+	"text/template"
+)
+
+//nolint:funlen
+func TestFuncMap(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{
+		"name":   "User/My_Repo",
+		"path":   "a/b/c.txt",
+		"source": "https://github.com/user/repo.git",
+		"empty":  "",
+	}
+
+	// SHA-256 of "abc"
+	const abcSHA256 = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+
+	tests := map[string]string{
+		`{{ .name | lower }}`:                                     "user/my_repo",
+		`{{ .name | upper }}`:                                     "USER/MY_REPO",
+		`{{ .name | replace "/" "-" }}`:                           "User-My_Repo",
+		`{{ .source | trimPrefix "https://" }}`:                   "github.com/user/repo.git",
+		`{{ .source | trimSuffix ".git" }}`:                       "https://github.com/user/repo",
+		`{{ .path | split "/" | join "-" }}`:                      "a-b-c.txt",
+		`{{ index (split "/" .path) 1 }}`:                         "b",
+		`{{ .path | base }}`:                                      "c.txt",
+		`{{ .path | dir }}`:                                       "a/b",
+		`{{ .source | regexReplace "^https://([^/]+)/.*" "$1" }}`: "github.com",
+		`{{ .empty | default "main" }}`:                           "main",
+		`{{ .missing | default "main" }}`:                         "main",
+		`{{ .name | default "main" }}`:                            "User/My_Repo",
+		`{{ coalesce .empty .missing "x" "y" }}`:                  "x",
+		`{{ .source | pathescape }}`:                              "https:%2F%2Fgithub.com%2Fuser%2Frepo.git",
+		`{{ .name | slug }}`:                                      "user-my-repo",
+		`{{ .name | sanitize }}`:                                  "User_My_Repo",
+		`{{ "abc" | sha1 }}`:                                      "a9993e364706816aba3e25717850c26c9cd0d89d",
+		`{{ "abc" | sha256 }}`:                                    abcSHA256,
+		`{{ "abc" | shorthash }}`:                                 "ba7816bf",
+	}
+
+	for in, want := range tests {
+		got, err := RenderStringE(in, vars)
+		if err != nil {
+			t.Errorf("RenderStringE(%q) = unexpected err %q", in, err)
+			continue
+		}
+
+		if got != want {
+			t.Errorf("RenderStringE(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{`{{ env "PATH" }}`, `{{ .name | regexReplace "(" "" }}`} {
+		if _, err := RenderStringE(in, vars); err == nil {
+			t.Errorf("RenderStringE(%q) = expected error", in)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Parallel()
+
+	env := Env("PATH")
+
+	if got, err := env("PATH"); err != nil || got != os.Getenv("PATH") {
+		t.Errorf("env(%q) = %q, %v", "PATH", got, err)
+	}
+
+	if _, err := env("HOME"); err == nil {
+		t.Errorf("env(%q) = expected error", "HOME")
+	}
+
+	vars := map[string]string{"a": `{{ env "PATH" }}`}
+	if err := RenderMap(vars, map[string]string{}, template.FuncMap{"env": env}); err != nil {
+		t.Errorf("RenderMap(%q) = unexpected err %q", vars, err)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"User/My_Repo.git": "user-my-repo-git",
+		"--a--b--":         "a-b",
+		"Ünï Côdé":         "ünï-côdé",
+		"":                 "",
+		"///":              "",
+	}
+
+	for in, want := range tests {
+		if got := Slug(in); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"a/b":        "a_b",
+		`a\b:c*d?e`:  "a_b_c_d_e",
+		`"<x>|`:      "__x__",
+		"tab\there":  "tab_here",
+		"name. . ":   "name",
+		"..":         "_",
+		"":           "_",
+		"ok-name.md": "ok-name.md",
+	}
+
+	for in, want := range tests {
+		if got := Sanitize(in); got != want {
+			t.Errorf("Sanitize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -2,9 +2,22 @@ package textutil
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"unicode"
 )
 
+// AssignmentError is a syntax error in an assignment expression with its position.
+type AssignmentError struct {
+	Line    int    // Line number starting from 1
+	Column  int    // Column (character offset in line) starting from 1
+	Message string // Error message without the position
+}
+
+func (e *AssignmentError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
 // Stolen from https://github.com/lib/pq/blob/master/conn.go
 
 // scanner implements a tokenizer for libpq-style option strings.
@@ -31,6 +44,16 @@ func (s *scanner) Next() (rune, bool) {
 	return r, true
 }
 
+// Peek returns the next rune without advancing.
+// It returns 0, false if the end of the text has been reached.
+func (s *scanner) Peek() (rune, bool) {
+	if s.i >= len(s.s) {
+		return 0, false
+	}
+
+	return s.s[s.i], true
+}
+
 // SkipSpaces returns the next non-whitespace rune.
 // It returns 0, false if the end of the text has been reached.
 func (s *scanner) SkipSpaces() (rune, bool) {
@@ -42,77 +65,396 @@ func (s *scanner) SkipSpaces() (rune, bool) {
 	return r, ok
 }
 
-// ParseAssignment parses assignment expression.
-func ParseAssignment(expr string, kv map[string]string) error { //nolint:gocognit,funlen
+// SkipLine skips the runes up to and including the next newline.
+func (s *scanner) SkipLine() {
+	for r, ok := s.Next(); ok && r != '\n'; r, ok = s.Next() {
+	}
+}
+
+// Errorf returns an AssignmentError at the given rune offset.
+func (s *scanner) Errorf(at int, format string, args ...interface{}) error {
+	e := &AssignmentError{Line: 1, Column: 1, Message: fmt.Sprintf(format, args...)}
+
+	for _, r := range s.s[:at] {
+		if r == '\n' {
+			e.Line++
+			e.Column = 1
+		} else {
+			e.Column++
+		}
+	}
+
+	return e
+}
+
+// ParseAssignment parses whitespace separated assignments in the style of libpq connection strings, e.g.
+// `a=1 b = 'x y' c="it\'s"`, into kv.  Values could be quoted with single or double quotes, where backslashes escape
+// the next character both inside and outside of quotes.  Spaces are allowed around "=", and a missing value at the
+// end is an empty string.  An AssignmentError is returned on syntax errors and duplicate keys, in which case kv is
+// left unchanged.
+func ParseAssignment(expr string, kv map[string]string) error {
+	return parseAssignment(expr, kv, false)
+}
+
+// ParseShellAssignment parses whitespace (including newline) separated assignments compatible with the shell, e.g.
+// `a=1 b='x y' c=$'it\'s'`, into kv.  Values are words made of unquoted, single quoted, double quoted and ANSI-C
+// quoted ($'...') parts with the escapes of the shell.  Comments start with "#" at the beginning of a word.  Since
+// expansions are not supported, unquoted or double quoted "$" and "`" are errors, as well as unquoted shell
+// operators.  Keys should be valid shell variable names without spaces around "=".  An AssignmentError is returned
+// on syntax errors and duplicate keys, in which case kv is left unchanged.
+func ParseShellAssignment(expr string, kv map[string]string) error {
+	return parseAssignment(expr, kv, true)
+}
+
+func parseAssignment(expr string, kv map[string]string, shell bool) error {
 	s := newScanner(expr)
+	parsed := map[string]string{}
 
 	for {
+		r, ok := s.SkipSpaces()
+		if !ok {
+			break
+		}
+
+		if shell && r == '#' {
+			s.SkipLine()
+
+			continue
+		}
+
+		start := s.i - 1
+
 		var (
-			keyRunes, valRunes []rune
-			r                  rune
-			ok                 bool
+			key, value string
+			err        error
 		)
 
-		if r, ok = s.SkipSpaces(); !ok {
-			break
+		if shell {
+			key, value, err = s.shellPair(r)
+		} else {
+			key, value, err = s.libpqPair(r)
 		}
 
-		// Scan the key
-		for !unicode.IsSpace(r) && r != '=' {
-			keyRunes = append(keyRunes, r)
+		if err != nil {
+			return err
+		}
 
-			if r, ok = s.Next(); !ok {
+		if _, ok := parsed[key]; ok {
+			return s.Errorf(start, "duplicate key %q", key)
+		}
+
+		parsed[key] = value
+	}
+
+	for k, v := range parsed {
+		kv[k] = v
+	}
+
+	return nil
+}
+
+// libpqPair scans a libpq-style assignment starting with the given rune.
+func (s *scanner) libpqPair(r rune) (string, string, error) {
+	var (
+		key   []rune
+		start = s.i - 1
+		ok    = true
+	)
+
+	for ok && !unicode.IsSpace(r) && r != '=' {
+		key = append(key, r)
+		r, ok = s.Next()
+	}
+
+	if len(key) == 0 {
+		return "", "", s.Errorf(start, `missing key before "="`)
+	}
+
+	// Skip any whitespace if we're not at the = yet
+	if ok && r != '=' {
+		r, ok = s.SkipSpaces()
+	}
+
+	if !ok || r != '=' {
+		return "", "", s.Errorf(start, `missing "=" after key %q`, string(key))
+	}
+
+	// If we reach the end here, the last value is just an empty string as per libpq.
+	if r, ok = s.SkipSpaces(); !ok {
+		return string(key), "", nil
+	}
+
+	value, err := s.libpqValue(r)
+
+	return string(key), value, err
+}
+
+func (s *scanner) libpqValue(r rune) (string, error) {
+	var (
+		value []rune
+		start = s.i - 1
+		ok    bool
+	)
+
+	if r == '\'' || r == '"' {
+		q := r
+
+		for {
+			if r, ok = s.Next(); r == '\\' && ok {
+				r, ok = s.Next()
+			} else if r == q {
 				break
 			}
+
+			if !ok {
+				return "", s.Errorf(start, "unterminated quoted value")
+			}
+
+			value = append(value, r)
 		}
 
-		// Skip any whitespace if we're not at the = yet
-		if r != '=' {
-			r, ok = s.SkipSpaces()
+		if r, ok = s.Peek(); ok && !unicode.IsSpace(r) {
+			return "", s.Errorf(s.i, "unexpected %q after quoted value", r)
 		}
 
-		// The current character should be =
-		if r != '=' || !ok {
-			return fmt.Errorf(`missing "=" after %q in expression"`, string(keyRunes))
+		return string(value), nil
+	}
+
+	for {
+		if r == '\\' {
+			if r, ok = s.Next(); !ok {
+				return "", s.Errorf(s.i-1, "trailing backslash")
+			}
 		}
 
-		// Skip any whitespace after the =
-		if r, ok = s.SkipSpaces(); !ok {
-			// If we reach the end here, the last value is just an empty string as per libpq.
-			kv[string(keyRunes)] = ""
+		value = append(value, r)
 
-			break
+		if r, ok = s.Next(); !ok || unicode.IsSpace(r) {
+			return string(value), nil
 		}
+	}
+}
 
-		if r != '\'' && r != '"' {
-			for {
-				valRunes = append(valRunes, r)
+func isShellNameRune(r rune, first bool) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || !first && r >= '0' && r <= '9'
+}
 
-				if r, ok = s.Next(); !ok {
-					break
-				}
+// shellPair scans a shell assignment starting with the given rune.
+func (s *scanner) shellPair(r rune) (string, string, error) {
+	var (
+		key   []rune
+		start = s.i - 1
+		ok    = true
+	)
+
+	for ok && isShellNameRune(r, len(key) == 0) {
+		key = append(key, r)
+		r, ok = s.Next()
+	}
+
+	switch {
+	case ok && r == '=' && len(key) > 0:
+	case ok && r == '=':
+		return "", "", s.Errorf(start, `missing key before "="`)
+	case !ok || unicode.IsSpace(r):
+		return "", "", s.Errorf(start, `missing "=" after key %q`, string(key))
+	default:
+		return "", "", s.Errorf(s.i-1, "invalid character %q in key", r)
+	}
+
+	value, err := s.shellWord()
+
+	return string(key), value, err
+}
+
+// shellWord scans a word up to the next unquoted whitespace.
+func (s *scanner) shellWord() (string, error) { //nolint:gocyclo
+	var value strings.Builder
+
+	for {
+		r, ok := s.Next()
+		if !ok || unicode.IsSpace(r) {
+			return value.String(), nil
+		}
+
+		at := s.i - 1
+
+		var err error
+
+		switch {
+		case r == '\'':
+			err = s.shellSingleQuoted(&value)
+		case r == '"':
+			err = s.shellDoubleQuoted(&value)
+		case r == '$':
+			if next, _ := s.Peek(); next != '\'' {
+				return "", s.Errorf(at, "expansions are not supported")
 			}
-		} else {
-			q := r
-		quote:
-			for {
-				if r, ok = s.Next(); !ok {
-					return fmt.Errorf(`unterminated quoted string literal in connection string`)
-				}
-				switch r {
-				case q:
-					break quote
-				case '\\':
-					r, _ = s.Next()
-					fallthrough
-				default:
-					valRunes = append(valRunes, r)
+
+			s.i++
+			err = s.shellANSIQuoted(&value)
+		case r == '`':
+			return "", s.Errorf(at, "expansions are not supported")
+		case strings.ContainsRune("|&;<>()", r):
+			return "", s.Errorf(at, "unexpected %q, should be quoted", r)
+		case r == '\\':
+			if r, ok = s.Next(); !ok {
+				return "", s.Errorf(at, "trailing backslash")
+			}
+
+			// Escaped newlines continue lines
+			if r != '\n' {
+				value.WriteRune(r)
+			}
+		default:
+			value.WriteRune(r)
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func (s *scanner) shellSingleQuoted(value *strings.Builder) error {
+	start := s.i - 1
+
+	for {
+		r, ok := s.Next()
+		if !ok {
+			return s.Errorf(start, "unterminated quoted value")
+		}
+
+		if r == '\'' {
+			return nil
+		}
+
+		value.WriteRune(r)
+	}
+}
+
+func (s *scanner) shellDoubleQuoted(value *strings.Builder) error {
+	start := s.i - 1
+
+	for {
+		r, ok := s.Next()
+		if !ok {
+			return s.Errorf(start, "unterminated quoted value")
+		}
+
+		switch r {
+		case '"':
+			return nil
+		case '$', '`':
+			return s.Errorf(s.i-1, "expansions are not supported")
+		case '\\':
+			// Backslashes escape only the characters special inside double quotes
+			if next, _ := s.Peek(); strings.ContainsRune("$`\"\\\n", next) {
+				s.i++
+
+				if next != '\n' {
+					value.WriteRune(next)
 				}
+
+				continue
+			}
+		}
+
+		value.WriteRune(r)
+	}
+}
+
+// Single character escapes of ANSI-C quoting
+var ansiEscapes = map[rune]rune{
+	'a': '\a', 'b': '\b', 'e': 0x1b, 'E': 0x1b, 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+	'\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+func (s *scanner) shellANSIQuoted(value *strings.Builder) error {
+	start := s.i - 2
+
+	for {
+		r, ok := s.Next()
+		if !ok {
+			return s.Errorf(start, "unterminated quoted value")
+		}
+
+		switch r {
+		case '\'':
+			return nil
+		case '\\':
+			if err := s.shellANSIEscape(value, start); err != nil {
+				return err
 			}
+		default:
+			value.WriteRune(r)
 		}
+	}
+}
 
-		kv[string(keyRunes)] = string(valRunes)
+func (s *scanner) shellANSIEscape(value *strings.Builder, start int) error {
+	r, ok := s.Next()
+	if !ok {
+		return s.Errorf(start, "unterminated quoted value")
+	}
+
+	if e, ok := ansiEscapes[r]; ok {
+		value.WriteRune(e)
+
+		return nil
+	}
+
+	switch r {
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		s.i--
+		value.WriteByte(byte(s.digits(8, 3)))
+	case 'x':
+		if n := s.digits(16, 2); n >= 0 {
+			value.WriteByte(byte(n))
+		} else {
+			value.WriteString(`\x`)
+		}
+	case 'u', 'U':
+		size := 4
+		if r == 'U' {
+			size = 8
+		}
+
+		if n := s.digits(16, size); n >= 0 && n <= unicode.MaxRune {
+			value.WriteRune(rune(n))
+		} else {
+			return s.Errorf(s.i-1, "invalid unicode escape")
+		}
+	default:
+		// Unknown escapes are kept as is
+		value.WriteRune('\\')
+		value.WriteRune(r)
 	}
 
 	return nil
 }
+
+// digits scans up to the given number of digits in the given base and returns their value, or -1 if there are none.
+func (s *scanner) digits(base, max int) int64 {
+	begin := s.i
+
+	for s.i < len(s.s) && s.i-begin < max && isDigit(s.s[s.i], base) {
+		s.i++
+	}
+
+	if s.i == begin {
+		return -1
+	}
+
+	n, _ := strconv.ParseInt(string(s.s[begin:s.i]), base, 64)
+
+	return n
+}
+
+func isDigit(r rune, base int) bool {
+	if base == 16 && (r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+		return true
+	}
+
+	return r >= '0' && r <= '9' && r < '0'+rune(base)
+}
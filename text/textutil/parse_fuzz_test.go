@@ -0,0 +1,71 @@
+//go:build go1.18
+// +build go1.18
+
+package textutil
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// quoteAssignments formats the given assignments to be parsed back by ParseAssignment or ParseShellAssignment.
+func quoteAssignments(kv map[string]string, shell bool) string {
+	ks := make([]string, 0, len(kv))
+	for k := range kv {
+		ks = append(ks, k)
+	}
+
+	sort.Strings(ks)
+
+	pairs := make([]string, 0, len(ks))
+
+	for _, k := range ks {
+		if shell {
+			pairs = append(pairs, k+"='"+strings.ReplaceAll(kv[k], "'", `'\''`)+"'")
+		} else {
+			pairs = append(pairs, k+"='"+strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(kv[k])+"'")
+		}
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func fuzzAssignment(f *testing.F, parse func(string, map[string]string) error, shell bool) {
+	for _, seed := range []string{
+		`a=1 b='x y' c="z"`, `a=x\ y`, "# c\na=1", `a=$'\x41\n'`, `a='it'\''s'`, `a=`, `a=1 a=2`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		kv := map[string]string{}
+		if err := parse(in, kv); err != nil {
+			if _, ok := err.(*AssignmentError); !ok {
+				t.Fatalf("parse(%q) = err %v, want an AssignmentError", in, err)
+			}
+
+			return
+		}
+
+		out := quoteAssignments(kv, shell)
+
+		got := map[string]string{}
+		if err := parse(out, got); err != nil {
+			t.Fatalf("parse(%q) = unexpected err %v for the quoted form of %q", out, err, in)
+		}
+
+		if !reflect.DeepEqual(got, kv) {
+			t.Fatalf("parse(%q) = %q, want %q", out, got, kv)
+		}
+	})
+}
+
+func FuzzParseAssignment(f *testing.F) {
+	fuzzAssignment(f, ParseAssignment, false)
+}
+
+func FuzzParseShellAssignment(f *testing.F) {
+	fuzzAssignment(f, ParseShellAssignment, true)
+}
@@ -0,0 +1,138 @@
+package textutil
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseAssignment(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]map[string]string{
+		``:                           {},
+		`a=1`:                        {"a": "1"},
+		`a=1 b=2`:                    {"a": "1", "b": "2"},
+		"  a = 1\n\tb =2  ":          {"a": "1", "b": "2"},
+		`a=`:                         {"a": ""},
+		`a='' b=""`:                  {"a": "", "b": ""},
+		`a='x y' b="z w"`:            {"a": "x y", "b": "z w"},
+		`a='it\'s' b="\"q\"" c='\\'`: {"a": "it's", "b": `"q"`, "c": `\`},
+		`a=x\ y b=\'`:                {"a": "x y", "b": "'"},
+		`x="{{ .name | upper }}"`:    {"x": "{{ .name | upper }}"},
+		`a==b`:                       {"a": "=b"},
+		`a=é b=ü`:                    {"a": "é", "b": "ü"},
+		"a='multi\nline'":            {"a": "multi\nline"},
+		`a=x#y b=#`:                  {"a": "x#y", "b": "#"},
+		`a=x'y'`:                     {"a": "x'y'"},
+	}
+
+	for in, want := range tests {
+		got := map[string]string{}
+
+		if err := ParseAssignment(in, got); err != nil {
+			t.Errorf("ParseAssignment(%q) = unexpected err %q", in, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseAssignment(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseShellAssignment(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]map[string]string{
+		``:                              {},
+		`a=1 b=2`:                       {"a": "1", "b": "2"},
+		"# comment\na=1 # trailing\n":   {"a": "1"},
+		`a=x#y b=#z`:                    {"a": "x#y", "b": "#z"},
+		`a=`:                            {"a": ""},
+		`a= b=1`:                        {"a": "", "b": "1"},
+		`a='x y' b="z w"`:               {"a": "x y", "b": "z w"},
+		`a='it'\''s'`:                   {"a": "it's"},
+		`a="\$ \" \\ \x"`:               {"a": `$ " \ \x`},
+		`a=x\ y\"z`:                     {"a": `x y"z`},
+		"a=x\\\ny":                      {"a": "xy"},
+		`a=$'it\'s\n\t\x41\101é'`:       {"a": "it's\n\tAAé"},
+		`a=$'\q\xZ'`:                    {"a": `\q\xZ`},
+		`a=pre'x'"y"$'z'post`:           {"a": "prexyzpost"},
+		`_A1='{{ .name | upper }}'`:     {"_A1": "{{ .name | upper }}"},
+		"a='multi\nline' b=2":           {"a": "multi\nline", "b": "2"},
+		`a=$'\377'`:                     {"a": "\xff"},
+		`a="{{ printf \"%s\" .name }}"`: {"a": `{{ printf "%s" .name }}`},
+	}
+
+	for in, want := range tests {
+		got := map[string]string{}
+
+		if err := ParseShellAssignment(in, got); err != nil {
+			t.Errorf("ParseShellAssignment(%q) = unexpected err %q", in, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseShellAssignment(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+type testAssignmentError struct {
+	in     string
+	shell  bool
+	line   int
+	column int
+}
+
+func TestAssignmentError(t *testing.T) {
+	t.Parallel()
+
+	tests := []testAssignmentError{
+		{`a`, false, 1, 1},
+		{`a=1 b`, false, 1, 5},
+		{`=1`, false, 1, 1},
+		{`a=1 b='x`, false, 1, 7},
+		{`a='x'y`, false, 1, 6},
+		{`a=x\`, false, 1, 4},
+		{"a=1\n  a=2", false, 2, 3},
+		{`a = 1`, true, 1, 1},
+		{`a-b=1`, true, 1, 2},
+		{`1a=1`, true, 1, 1},
+		{`=1`, true, 1, 1},
+		{"a=1\nb='x", true, 2, 3},
+		{`a=$HOME`, true, 1, 3},
+		{`a="$HOME"`, true, 1, 4},
+		{"a=`x`", true, 1, 3},
+		{`a=x;y`, true, 1, 4},
+		{`a=$'\u{1}`, true, 1, 6},
+		{`a=1 a=2`, true, 1, 5},
+	}
+
+	for _, tc := range tests {
+		kv := map[string]string{"kept": "x"}
+
+		var err error
+		if tc.shell {
+			err = ParseShellAssignment(tc.in, kv)
+		} else {
+			err = ParseAssignment(tc.in, kv)
+		}
+
+		var ae *AssignmentError
+		if !errors.As(err, &ae) {
+			t.Errorf("ParseAssignment(%q, shell=%v) = err %v, want an AssignmentError", tc.in, tc.shell, err)
+			continue
+		}
+
+		if ae.Line != tc.line || ae.Column != tc.column {
+			t.Errorf("ParseAssignment(%q, shell=%v) = %d:%d %q, want %d:%d", tc.in, tc.shell, ae.Line, ae.Column,
+				ae.Message, tc.line, tc.column)
+		}
+
+		if len(kv) != 1 {
+			t.Errorf("ParseAssignment(%q, shell=%v) = changed kv on error: %q", tc.in, tc.shell, kv)
+		}
+	}
+}
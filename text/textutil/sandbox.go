@@ -0,0 +1,488 @@
+package textutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Errors returned when the resource limits of a sandbox are exceeded
+var (
+	ErrOutputLimit = errors.New("template output limit exceeded")
+	ErrResultLimit = errors.New("template function result limit exceeded")
+	ErrStepLimit   = errors.New("template step limit exceeded")
+	ErrDepthLimit  = errors.New("template nesting depth limit exceeded")
+	ErrTimeout     = errors.New("template execution timed out")
+)
+
+// Functions of FuncMap which are not allowed in sandboxes by default since they access the environment
+var unsafeFuncs = map[string]bool{
+	"env": true,
+	"pwd": true,
+}
+
+// Default limits of sandboxes
+const (
+	DefaultMaxOutput = 64 * 1024
+	DefaultMaxSteps  = 10000
+	DefaultMaxDepth  = 100
+	DefaultTimeout   = time.Second
+)
+
+// Sandbox renders untrusted templates safely with a function allowlist and resource limits.  Steps are the template
+// calls, the executed lists of actions (e.g. each iteration of range), the function calls and the output writes made
+// while executing a template.  Limits apply to each rendering, e.g. to all the templates rendered by RenderMap
+// together.
+type Sandbox struct {
+	Funcs     []string      // Allowed functions of FuncMap; nil to allow all but the ones accessing the environment
+	MaxOutput int           // Maximum size in bytes of the output of each template and function result; 0 for unlimited
+	MaxSteps  int           // Maximum number of steps; 0 for unlimited
+	MaxDepth  int           // Maximum nesting depth of template calls; 0 for the limit of text/template
+	Timeout   time.Duration // Maximum execution time; 0 for unlimited
+}
+
+// NewSandbox returns a sandbox with the default function allowlist and limits.
+func NewSandbox() *Sandbox {
+	return &Sandbox{
+		MaxOutput: DefaultMaxOutput,
+		MaxSteps:  DefaultMaxSteps,
+		MaxDepth:  DefaultMaxDepth,
+		Timeout:   DefaultTimeout,
+	}
+}
+
+// Parse parses the given template text with the allowed functions and the given trusted functions, hence using the
+// other functions fails.  Unlike the allowed functions, the trusted functions consume no steps and their results are
+// not limited.
+func (sb *Sandbox) Parse(name, text string, funcs ...template.FuncMap) (*template.Template, error) {
+	t, err := template.New(name).Funcs(sb.funcMap(nil, funcs...)).Parse(text)
+	if err != nil {
+		return nil, newTemplateError(name, err)
+	}
+
+	return t, nil
+}
+
+// Render applies the data structure 'vars' onto a template parsed by Parse within the limits.
+func (sb *Sandbox) Render(t *template.Template, vars interface{}) (string, error) {
+	b := sb.newBudget()
+
+	// Bind the functions to the budget of this rendering, and copy the parse trees shared by the clone to instrument
+	t, err := t.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			tmpl.Tree = tmpl.Tree.Copy()
+		}
+	}
+
+	b.instrument(t.Funcs(sb.funcMap(b)))
+
+	return sb.execute(t, vars, b)
+}
+
+// RenderString parses and renders the given template text; see Render.
+func (sb *Sandbox) RenderString(str string, vars interface{}) (string, error) {
+	t, err := sb.Parse("tmpl", str)
+	if err != nil {
+		return "", err
+	}
+
+	return sb.Render(t, vars)
+}
+
+// RenderMap is the sandboxed version of the package level RenderMap where the given functions are trusted; see Parse.
+func (sb *Sandbox) RenderMap(templateMap map[string]string, vars map[string]string, funcs ...template.FuncMap) error {
+	return sb.RenderData(templateMap, vars, funcs...)
+}
+
+// RenderData is the sandboxed version of the package level RenderData.  Methods of the data are not limited.
+func (sb *Sandbox) RenderData(templateMap map[string]string, data interface{}, funcs ...template.FuncMap) error {
+	b := sb.newBudget()
+
+	set := template.New("").Funcs(sb.funcMap(b, funcs...))
+	instrumented := false
+
+	return renderMap(set, templateMap, data, func(t *template.Template, data interface{}) (string, error) {
+		// All the templates are parsed by now
+		if !instrumented {
+			b.instrument(set)
+			instrumented = true
+		}
+
+		return sb.execute(t, data, b)
+	})
+}
+
+// execute executes the template in a separate goroutine to give up after the timeout.  The abandoned execution stops
+// at its next step since the deadline has passed by then, unless it is blocked in a method of the data or a trusted
+// function.
+func (sb *Sandbox) execute(t *template.Template, vars interface{}, b *budget) (string, error) {
+	w := &limitedWriter{max: sb.MaxOutput, budget: b}
+	done := make(chan error, 1)
+
+	go func() {
+		done <- t.Execute(w, vars)
+	}()
+
+	var timeout <-chan time.Time
+
+	if sb.Timeout > 0 {
+		timer := time.NewTimer(sb.Timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		if b.err != nil {
+			return "", &TemplateError{Name: t.Name(), Message: b.err.Error(), Err: b.err}
+		}
+
+		if err != nil {
+			return "", newTemplateError(t.Name(), err)
+		}
+
+		return w.buf.String(), nil
+	case <-timeout:
+		return "", &TemplateError{Name: t.Name(), Message: ErrTimeout.Error(), Err: ErrTimeout}
+	}
+}
+
+// funcMap returns the allowed functions consuming steps of the given budget unless nil, along with the given trusted
+// functions as is.
+func (sb *Sandbox) funcMap(b *budget, funcs ...template.FuncMap) template.FuncMap {
+	allowed := map[string]bool{}
+
+	if sb.Funcs == nil {
+		for name := range FuncMap() {
+			allowed[name] = !unsafeFuncs[name]
+		}
+	} else {
+		for _, name := range sb.Funcs {
+			allowed[name] = true
+		}
+	}
+
+	m := template.FuncMap{
+		// Override the builtin formatting functions to count their steps and to limit the widths
+		"print":   fmt.Sprint,
+		"println": fmt.Sprintln,
+		"printf":  sprintf,
+	}
+
+	for name, fn := range FuncMap() {
+		if allowed[name] {
+			m[name] = fn
+		}
+	}
+
+	if b != nil {
+		// Override the functions having results much larger than their arguments to fail before allocating them
+		for name, fn := range map[string]interface{}{
+			"join":         b.join,
+			"regexReplace": b.regexReplace,
+			"replace":      b.replace,
+		} {
+			if allowed[name] {
+				m[name] = fn
+			}
+		}
+	}
+
+	if b != nil {
+		for name, fn := range m {
+			m[name] = b.wrap(fn)
+		}
+	}
+
+	for _, f := range funcs {
+		for name, fn := range f {
+			m[name] = fn
+		}
+	}
+
+	return m
+}
+
+func (sb *Sandbox) newBudget() *budget {
+	b := &budget{max: sb.MaxSteps, maxSize: sb.MaxOutput, maxDepth: sb.MaxDepth}
+
+	if sb.Timeout > 0 {
+		b.deadline = time.Now().Add(sb.Timeout)
+	}
+
+	return b
+}
+
+// budget tracks the steps of a rendering which is executed by a single goroutine at a time.
+type budget struct {
+	steps    int
+	max      int
+	maxSize  int
+	depth    int
+	maxDepth int
+	deadline time.Time
+	err      error
+}
+
+func (b *budget) step() error {
+	if b.err != nil {
+		return b.err
+	}
+
+	b.steps++
+
+	switch {
+	case b.max > 0 && b.steps > b.max:
+		b.err = ErrStepLimit
+	case !b.deadline.IsZero() && time.Now().After(b.deadline):
+		b.err = ErrTimeout
+	}
+
+	return b.err
+}
+
+// checkSize fails if the given size of a function result exceeds the maximum.
+func (b *budget) checkSize(size int) error {
+	if b.err == nil && b.maxSize > 0 && size > b.maxSize {
+		b.err = ErrResultLimit
+	}
+
+	return b.err
+}
+
+// Names of the functions inserted into the parse trees by instrument, which are not available to the templates since
+// they are defined after parsing
+const (
+	stepFunc  = "_step"
+	enterFunc = "_enter"
+	leaveFunc = "_leave"
+)
+
+// instrument inserts the calls of the budget functions into the parse trees of the given template and the ones
+// associated with it, so that each template call and each executed list of actions takes a step.
+func (b *budget) instrument(t *template.Template) {
+	t.Funcs(template.FuncMap{
+		stepFunc: func() (string, error) {
+			return "", b.step()
+		},
+		enterFunc: func() (string, error) {
+			if b.depth++; b.maxDepth > 0 && b.depth > b.maxDepth && b.err == nil {
+				b.err = ErrDepthLimit
+			}
+
+			return "", b.step()
+		},
+		leaveFunc: func() string {
+			b.depth--
+
+			return ""
+		},
+	})
+
+	seen := map[*parse.Tree]bool{}
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil && tmpl.Tree.Root != nil && !seen[tmpl.Tree] {
+			seen[tmpl.Tree] = true
+
+			instrumentList(tmpl.Tree, tmpl.Tree.Root, true)
+		}
+	}
+}
+
+func instrumentList(tree *parse.Tree, list *parse.ListNode, root bool) {
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			instrumentList(tree, n.List, false)
+			instrumentList(tree, n.ElseList, false)
+		case *parse.RangeNode:
+			instrumentList(tree, n.List, false)
+			instrumentList(tree, n.ElseList, false)
+		case *parse.WithNode:
+			instrumentList(tree, n.List, false)
+			instrumentList(tree, n.ElseList, false)
+		}
+	}
+
+	if root {
+		list.Nodes = append(append([]parse.Node{callNode(tree, list.Pos, enterFunc)}, list.Nodes...),
+			callNode(tree, list.Pos, leaveFunc))
+	} else {
+		list.Nodes = append([]parse.Node{callNode(tree, list.Pos, stepFunc)}, list.Nodes...)
+	}
+}
+
+// callNode returns an action calling the given function without arguments, e.g. "{{ _step }}".
+func callNode(tree *parse.Tree, pos parse.Pos, name string) parse.Node {
+	return &parse.ActionNode{NodeType: parse.NodeAction, Pos: pos, Pipe: &parse.PipeNode{
+		NodeType: parse.NodePipe,
+		Pos:      pos,
+		Cmds: []*parse.CommandNode{{
+			NodeType: parse.NodeCommand,
+			Pos:      pos,
+			Args:     []parse.Node{parse.NewIdentifier(name).SetTree(tree).SetPos(pos)},
+		}},
+	}}
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// wrap returns the given template function wrapped to consume a step before each call and to fail if the result
+// exceeds the maximum size.
+func (b *budget) wrap(fn interface{}) interface{} {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+
+	in := make([]reflect.Type, t.NumIn())
+	for i := range in {
+		in[i] = t.In(i)
+	}
+
+	ft := reflect.FuncOf(in, []reflect.Type{t.Out(0), errorType}, t.IsVariadic())
+
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		if err := b.step(); err != nil {
+			return []reflect.Value{reflect.Zero(t.Out(0)), reflect.ValueOf(&err).Elem()}
+		}
+
+		var out []reflect.Value
+
+		if t.IsVariadic() {
+			out = v.CallSlice(args)
+		} else {
+			out = v.Call(args)
+		}
+
+		if len(out) == 1 {
+			out = append(out, reflect.Zero(errorType))
+		}
+
+		if err := b.checkSize(resultSize(out[0])); err != nil {
+			return []reflect.Value{reflect.Zero(t.Out(0)), reflect.ValueOf(&err).Elem()}
+		}
+
+		return out
+	}).Interface()
+}
+
+// resultSize returns the length of the given string, slice or map.
+func resultSize(v reflect.Value) int {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len()
+	}
+
+	return 0
+}
+
+// join is the version of join checking the size of the result beforehand.
+func (b *budget) join(sep string, elems []string) (string, error) {
+	size := 0
+	if len(elems) > 0 {
+		size = (len(elems) - 1) * len(sep)
+	}
+
+	for _, elem := range elems {
+		size += len(elem)
+	}
+
+	if err := b.checkSize(size); err != nil {
+		return "", err
+	}
+
+	return join(sep, elems), nil
+}
+
+// replace is the version of replace checking the size of the result beforehand.
+func (b *budget) replace(old, new, s string) (string, error) {
+	if err := b.checkSize(len(s) + strings.Count(s, old)*(len(new)-len(old))); err != nil {
+		return "", err
+	}
+
+	return replace(old, new, s), nil
+}
+
+// regexReplace is the version of regexReplace checking the size of the result before expanding each match, where
+// each reference to a group in the replacement is assumed to expand to the whole match.
+func (b *budget) regexReplace(pattern, repl, s string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		buf  []byte
+		last int
+		refs = strings.Count(repl, "$")
+	)
+
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		if err := b.checkSize(len(buf) + m[0] - last + len(repl) + refs*(m[1]-m[0]) + len(s) - m[1]); err != nil {
+			return "", err
+		}
+
+		buf = append(buf, s[last:m[0]]...)
+		buf = re.ExpandString(buf, repl, s, m)
+		last = m[1]
+	}
+
+	return string(append(buf, s[last:]...)), nil
+}
+
+// limitedWriter is an output buffer which consumes a step for each write and fails beyond the maximum size.
+type limitedWriter struct {
+	buf    bytes.Buffer
+	max    int
+	budget *budget
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	// Actions printing nothing (e.g. the ones inserted by instrument) take no steps
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if err := w.budget.step(); err != nil {
+		return 0, err
+	}
+
+	if w.max > 0 && w.buf.Len()+len(p) > w.max {
+		w.budget.err = ErrOutputLimit
+
+		return 0, ErrOutputLimit
+	}
+
+	return w.buf.Write(p)
+}
+
+// Formatting verbs with widths or precisions long enough to allocate excessive memory
+var reLargeWidth = regexp.MustCompile(`%[-+# 0]*([0-9]{5,}|[0-9]*\.[0-9]{5,}|\*)`)
+
+func sprintf(format string, args ...interface{}) (string, error) {
+	if reLargeWidth.MatchString(format) {
+		return "", fmt.Errorf("format %q has too large or dynamic widths", format)
+	}
+
+	return fmt.Sprintf(format, args...), nil
+}
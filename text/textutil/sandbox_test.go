@@ -0,0 +1,216 @@
+package textutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestSandbox(t *testing.T) {
+	t.Parallel()
+
+	sb := NewSandbox()
+	vars := map[string]string{"name": "User/Repo"}
+
+	tests := map[string]string{
+		`{{ .name | lower | slug }}`:       "user-repo",
+		`{{ printf "%s-%d" .name 1 }}`:     "User/Repo-1",
+		`{{ print .name }}`:                "User/Repo",
+		`{{ .name | shorthash | len }}`:    "8",
+		`{{ if eq .name "x" }}x{{ end }}y`: "y",
+	}
+
+	for in, want := range tests {
+		got, err := sb.RenderString(in, vars)
+		if err != nil {
+			t.Errorf("RenderString(%q) = unexpected err %q", in, err)
+			continue
+		}
+
+		if got != want {
+			t.Errorf("RenderString(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{`{{ pwd }}`, `{{ env "HOME" }}`, `{{ printf "%099999d" 1 }}`, `{{ printf "%*d" 9 1 }}`} {
+		if _, err := sb.RenderString(in, vars); err == nil {
+			t.Errorf("RenderString(%q) = expected error", in)
+		}
+	}
+}
+
+func TestSandboxAllowlist(t *testing.T) {
+	t.Parallel()
+
+	sb := &Sandbox{Funcs: []string{"lower"}}
+
+	if got, err := sb.RenderString(`{{ "A" | lower }}`, nil); err != nil || got != "a" {
+		t.Errorf("RenderString() = %q, %v", got, err)
+	}
+
+	if _, err := sb.RenderString(`{{ "A" | upper }}`, nil); err == nil {
+		t.Errorf("RenderString() = expected error for a function not allowed")
+	}
+
+	tmpl, err := sb.Parse("t", `{{ "A" | upper | twice }}`, template.FuncMap{
+		"twice": func(s string) string { return s + s },
+	})
+	if err == nil {
+		t.Errorf("Parse() = expected error for a function not allowed, got %v", tmpl)
+	}
+}
+
+type testSandboxLimit struct {
+	sandbox *Sandbox
+	in      string
+	want    error
+}
+
+func TestSandboxLimits(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]interface{}{
+		"list": make([]int, 1000),
+		"long": strings.Repeat("x", 1000),
+		"slow": func() string { time.Sleep(time.Second); return "" },
+	}
+
+	tests := []testSandboxLimit{
+		{&Sandbox{MaxOutput: 100}, `{{ .long }}`, ErrOutputLimit},
+		{&Sandbox{MaxOutput: 100}, `{{ range .list }}x{{ end }}`, ErrOutputLimit},
+		{&Sandbox{MaxSteps: 10}, `{{ range .list }}x{{ end }}`, ErrStepLimit},
+		{&Sandbox{MaxSteps: 10}, `{{ range .list }}{{ "a" | upper | lower }}{{ end }}`, ErrStepLimit},
+		{&Sandbox{Timeout: 10 * time.Millisecond}, `{{ call .slow }}`, ErrTimeout},
+		{&Sandbox{MaxSteps: 10}, `{{ range .list }}{{ end }}`, ErrStepLimit},
+		{&Sandbox{MaxSteps: 10}, `{{ define "r" }}{{ template "r" }}{{ end }}{{ template "r" }}`, ErrStepLimit},
+		{&Sandbox{MaxDepth: 10}, `{{ define "r" }}{{ template "r" }}{{ end }}{{ template "r" }}`, ErrDepthLimit},
+		{&Sandbox{MaxOutput: 100}, `{{ split "" .long | len }}`, ErrResultLimit},
+		{&Sandbox{MaxOutput: 100}, `{{ $s := "aa" }}{{ range .list }}{{ $s = replace "a" $s $s }}{{ end }}`, ErrResultLimit},
+		{&Sandbox{MaxOutput: 100}, `{{ split "" .long | join .long | len }}`, ErrResultLimit},
+		{&Sandbox{MaxOutput: 100}, `{{ regexReplace "x+" "${0}${0}" .long | len }}`, ErrResultLimit},
+	}
+
+	for _, tc := range tests {
+		_, err := tc.sandbox.RenderString(tc.in, vars)
+
+		var te *TemplateError
+		if !errors.Is(err, tc.want) || !errors.As(err, &te) {
+			t.Errorf("RenderString(%q) = err %v, want %v", tc.in, err, tc.want)
+		}
+	}
+
+	sb := &Sandbox{MaxOutput: 1000, MaxSteps: 2}
+	if got, err := sb.RenderString(`{{ .long }}`, vars); err != nil || got != vars["long"] {
+		t.Errorf("RenderString() = unexpected err %v", err)
+	}
+
+	// Rendering leaves the parsed template intact
+	tmpl, err := sb.Parse("t", `{{ .long }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := sb.Render(tmpl, vars); err != nil {
+			t.Errorf("Render() = unexpected err %v", err)
+		}
+	}
+}
+
+type testTicker struct {
+	n int64
+}
+
+func (c *testTicker) Tick() bool {
+	atomic.AddInt64(&c.n, 1)
+
+	return false
+}
+
+func TestSandboxTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Each template calls the previous one twice, which makes 2^40 calls without any output or function call
+	var text strings.Builder
+
+	text.WriteString(`{{ define "t0" }}{{ if .Tick }}{{ end }}{{ end }}`)
+
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&text, `{{ define "t%d" }}{{ template "t%d" . }}{{ template "t%d" . }}{{ end }}`, i, i-1, i-1)
+	}
+
+	text.WriteString(`{{ template "t40" . }}`)
+
+	sb := &Sandbox{Timeout: 20 * time.Millisecond}
+
+	tmpl, err := sb.Parse("t", text.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticker := &testTicker{}
+
+	if _, err := sb.Render(tmpl, ticker); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Render() = err %v, want %v", err, ErrTimeout)
+	}
+
+	// Give the abandoned execution the time to reach its next step
+	time.Sleep(10 * time.Millisecond)
+
+	n := atomic.LoadInt64(&ticker.n)
+
+	time.Sleep(50 * time.Millisecond)
+
+	if got := atomic.LoadInt64(&ticker.n); got != n {
+		t.Errorf("Render() = execution continued after the timeout with %d more calls", got-n)
+	}
+}
+
+func TestSandboxRenderMap(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{"name": "repo"}
+
+	err := NewSandbox().RenderMap(map[string]string{"a": "{{ .name | upper }}", "b": "{{ .a }}-x"}, vars)
+	if err != nil || vars["b"] != "REPO-x" {
+		t.Errorf("RenderMap() = %q, %v", vars["b"], err)
+	}
+
+	err = (&Sandbox{MaxSteps: 3}).RenderMap(map[string]string{"a": "{{ .name }}-{{ .name }}", "b": "{{ .a }}-x"}, vars)
+	if !errors.Is(err, ErrStepLimit) {
+		t.Errorf("RenderMap() = err %v, want %v", err, ErrStepLimit)
+	}
+}
+
+func TestSandboxTrustedFuncs(t *testing.T) {
+	t.Parallel()
+
+	sb := &Sandbox{MaxSteps: 10}
+	funcs := template.FuncMap{"twice": func(s string) string { return s + s }}
+
+	// Trusted functions consume no steps, unlike the allowed ones
+	text := strings.Repeat(`{{ $s := twice "a" }}`, 20) + `x`
+
+	tmpl, err := sb.Parse("t", text, funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := sb.Render(tmpl, nil); err != nil || got != "x" {
+		t.Errorf("Render() = %q, %v", got, err)
+	}
+
+	vars := map[string]string{}
+	if err := sb.RenderMap(map[string]string{"a": text}, vars, funcs); err != nil || vars["a"] != "x" {
+		t.Errorf("RenderMap() = %q, %v", vars["a"], err)
+	}
+
+	text = strings.Repeat(`{{ $s := lower "a" }}`, 20) + `x`
+	if err := sb.RenderMap(map[string]string{"a": text}, vars, funcs); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("RenderMap() = err %v, want %v", err, ErrStepLimit)
+	}
+}
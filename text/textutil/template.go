@@ -2,46 +2,363 @@ package textutil
 
 import (
 	"bytes"
-	"net/url"
-	"os"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
+	"text/template/parse"
 )
 
-// process applies the data structure 'vars' onto an already
-// parsed template 't', and returns the resulting string.
-func render(t *template.Template, vars interface{}) string {
+// TemplateError is a template parse or execute error with the position in the template.
+type TemplateError struct {
+	Name    string // Template name
+	Line    int    // Line number starting from 1, or 0 if unknown
+	Column  int    // Column (byte offset in line) starting from 1, or 0 if unknown
+	Message string // Error message without the position
+	Err     error  // Original error
+}
+
+func (e *TemplateError) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("template %q: %s", e.Name, e.Message)
+	case e.Column == 0:
+		return fmt.Sprintf("template %q line %d: %s", e.Name, e.Line, e.Message)
+	}
+
+	return fmt.Sprintf("template %q line %d, column %d: %s", e.Name, e.Line, e.Column, e.Message)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Errors of text/template are in the "template: NAME:LINE[:COLUMN]: MESSAGE" form
+var reTemplateError = regexp.MustCompile(`^template: (.*?):([0-9]+):(?:([0-9]+):)? (.*)$`)
+
+func newTemplateError(name string, err error) error {
+	te := &TemplateError{Name: name, Message: err.Error(), Err: err}
+
+	if m := reTemplateError.FindStringSubmatch(err.Error()); m != nil {
+		te.Name = m[1]
+		te.Line, _ = strconv.Atoi(m[2])
+		te.Column, _ = strconv.Atoi(m[3])
+		te.Message = m[4]
+	}
+
+	return te
+}
+
+// Parse parses the given template text with the functions of FuncMap extended by the given functions.
+func Parse(name, text string, funcs ...template.FuncMap) (*template.Template, error) {
+	t, err := template.New(name).Funcs(funcMap(funcs...)).Parse(text)
+	if err != nil {
+		return nil, newTemplateError(name, err)
+	}
+
+	return t, nil
+}
+
+// Render applies the data structure 'vars' onto an already parsed template 't', and returns the resulting string.
+func Render(t *template.Template, vars interface{}) (string, error) {
 	var tmplBytes bytes.Buffer
 
-	err := t.Execute(&tmplBytes, vars)
+	if err := t.Execute(&tmplBytes, vars); err != nil {
+		return "", newTemplateError(t.Name(), err)
+	}
+
+	return tmplBytes.String(), nil
+}
+
+// RenderStringE parses and renders the given template text; see Render.
+func RenderStringE(str string, vars interface{}) (string, error) {
+	tmpl, err := Parse("tmpl", str)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	return tmplBytes.String()
+	return Render(tmpl, vars)
 }
 
+// RenderString is like RenderStringE but panics on errors.
 func RenderString(str string, vars interface{}) string {
-	tmpl, err := template.New("tmpl").Funcs(FuncMap()).Parse(str)
-
+	s, err := RenderStringE(str, vars)
 	if err != nil {
 		panic(err)
 	}
 
-	return render(tmpl, vars)
+	return s
 }
 
-func FuncMap() template.FuncMap {
-	return template.FuncMap{
-		"pathescape": url.PathEscape,
+// RenderMap renders the given templates named after variables into vars in dependency order, so that a template
+// could refer to the other variables as well as to the existing ones in vars, e.g. "{{ .a }}-x" is rendered after the
+// template of "a".  A template referring to its own variable sees the existing value.  Since references are found
+// statically, the fields of other values (e.g. inside "with") are considered references too.  An error is returned
+// for cyclic references.  Templates could use the functions of FuncMap extended by the given functions.
+func RenderMap(templateMap map[string]string, vars map[string]string, funcs ...template.FuncMap) error {
+	return RenderData(templateMap, vars, funcs...)
+}
 
-		"pwd": func() string {
-			pwd, err := os.Getwd()
+// RenderData is like RenderMap but renders into the given data which is a map having string keys and values which
+// strings are assignable to, e.g. a map[string]interface{} or a named map type having methods callable from
+// templates.
+func RenderData(templateMap map[string]string, data interface{}, funcs ...template.FuncMap) error {
+	return renderMap(template.New("").Funcs(funcMap(funcs...)), templateMap, data, Render)
+}
+
+// renderMap implements RenderData for the given template set having the functions and the given render function.
+func renderMap(
+	set *template.Template, templateMap map[string]string, data interface{},
+	render func(*template.Template, interface{}) (string, error),
+) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String ||
+		!reflect.TypeOf("").AssignableTo(v.Type().Elem()) {
+		return fmt.Errorf("unsupported template data of type %T", data)
+	}
+
+	key := func(name string) reflect.Value {
+		return reflect.ValueOf(name).Convert(v.Type().Key())
+	}
+
+	names := make([]string, 0, len(templateMap))
+	for name := range templateMap {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := set.New(name).Parse(templateMap[name]); err != nil {
+			return newTemplateError(name, err)
+		}
+	}
+
+	order, err := dependencyOrder(set, names, func(name string) bool {
+		return v.MapIndex(key(name)).IsValid()
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range order {
+		s, err := render(set.Lookup(name), data)
+		if err != nil {
+			return err
+		}
+
+		v.SetMapIndex(key(name), reflect.ValueOf(s))
+	}
+
+	return nil
+}
+
+// ParseTemplates parses a file of named templates which is either a Go template consisting of "define" blocks, or a
+// list of assignments one per line in the form accepted by ParseAssignment, e.g. 'clone="git clone {{ .source }}"'
+// where blank lines and lines starting with "#" are skipped.  The file is considered a Go template if its first line
+// which is not skipped starts with "{{".  The returned templates could be rendered by RenderMap, where the ones
+// defined in the same file could also be invoked by the "template" action.  Templates could use the functions of
+// FuncMap extended by the given functions.
+func ParseTemplates(name, text string, funcs ...template.FuncMap) (map[string]string, error) {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "{{") {
+			return parseDefines(name, text, funcs...)
+		}
+
+		break
+	}
+
+	return parseAssignments(name, text)
+}
+
+func parseDefines(name, text string, funcs ...template.FuncMap) (map[string]string, error) {
+	set, err := Parse(name, text, funcs...)
+	if err != nil {
+		return nil, err
+	}
 
-			if err != nil {
-				panic(err)
+	templateMap := map[string]string{}
+
+	for _, t := range set.Templates() {
+		if t.Name() != name {
+			templateMap[t.Name()] = t.Tree.Root.String()
+
+			continue
+		}
+
+		for _, node := range t.Tree.Root.Nodes {
+			if n, ok := node.(*parse.TextNode); !ok || len(bytes.TrimSpace(n.Text)) > 0 {
+				return nil, &TemplateError{Name: name, Message: "unexpected content outside of define blocks"}
+			}
+		}
+	}
+
+	return templateMap, nil
+}
+
+func parseAssignments(name, text string) (map[string]string, error) {
+	templateMap := map[string]string{}
+
+	for i, line := range strings.Split(text, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		kv := map[string]string{}
+
+		if err := ParseAssignment(line, kv); err != nil {
+			te := &TemplateError{Name: name, Line: i + 1, Message: err.Error(), Err: err}
+
+			var ae *AssignmentError
+			if errors.As(err, &ae) {
+				te.Column, te.Message = ae.Column, ae.Message
+			}
+
+			return nil, te
+		}
+
+		for k, v := range kv {
+			if _, ok := templateMap[k]; ok {
+				return nil, &TemplateError{Name: name, Line: i + 1, Message: fmt.Sprintf("duplicate template %q", k)}
+			}
+
+			templateMap[k] = v
+		}
+	}
+
+	return templateMap, nil
+}
+
+// dependencyOrder returns the given template names sorted topologically by their references.
+func dependencyOrder(set *template.Template, names []string, exists func(string) bool) ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[string]int{}
+	order := make([]string, 0, len(names))
+
+	var visit func(name string, path []string) error
+
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("cyclic template variables: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+
+		for _, ref := range references(set.Lookup(name)) {
+			if ref == name && exists(ref) || set.Lookup(ref) == nil {
+				continue
 			}
 
-			return pwd
-		},
+			if err := visit(ref, append(path, name)); err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+		order = append(order, name)
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// references returns the sorted names of the top level fields, e.g. "a" for ".a.b" or "$.a", referred in the template.
+func references(t *template.Template) []string {
+	refs := map[string]bool{}
+
+	var walk func(node parse.Node)
+
+	walk = func(node parse.Node) { //nolint:gocyclo
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				for _, child := range n.Nodes {
+					walk(child)
+				}
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n != nil {
+				for _, cmd := range n.Cmds {
+					walk(cmd)
+				}
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.FieldNode:
+			refs[n.Ident[0]] = true
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				refs[n.Ident[1]] = true
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+
+	if t != nil && t.Tree != nil {
+		walk(t.Tree.Root)
 	}
+
+	ks := make([]string, 0, len(refs))
+	for k := range refs {
+		ks = append(ks, k)
+	}
+
+	sort.Strings(ks)
+
+	return ks
+}
+
+// funcMap returns FuncMap extended (or overridden) by the given functions.
+func funcMap(funcs ...template.FuncMap) template.FuncMap {
+	m := FuncMap()
+
+	for _, f := range funcs {
+		for name, fn := range f {
+			m[name] = fn
+		}
+	}
+
+	return m
 }
@@ -0,0 +1,224 @@
+package textutil
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRenderStringE(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{"name": "repo", "source": "https://example.com/repo"}
+
+	tests := map[string]string{
+		"{{ .name }}":                    "repo",
+		"{{ .source | pathescape }}":     "https:%2F%2Fexample.com%2Frepo",
+		"plain":                          "plain",
+		`{{ if .name }}yes{{ end }}`:     "yes",
+		`{{ .missing }}`:                 "<no value>",
+		`{{ printf "%s-%s" .name "x" }}`: "repo-x",
+	}
+
+	for in, want := range tests {
+		got, err := RenderStringE(in, vars)
+		if err != nil {
+			t.Errorf("RenderStringE(%q) = unexpected err %q", in, err)
+			continue
+		}
+
+		if got != want {
+			t.Errorf("RenderStringE(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+type testTemplateError struct {
+	in     string
+	line   int
+	column int
+}
+
+func TestTemplateError(t *testing.T) {
+	t.Parallel()
+
+	tests := []testTemplateError{
+		{"{{ .name | nope }}", 1, 0},
+		{"{{ .name }", 1, 0},
+		{"ok\n{{ if .name }}", 2, 0},
+		{"ok\n\n  {{ index .name 1 2 }}", 3, 5},
+	}
+
+	vars := map[string]string{"name": "repo"}
+
+	for _, tc := range tests {
+		_, err := RenderStringE(tc.in, vars)
+
+		var te *TemplateError
+		if !errors.As(err, &te) {
+			t.Errorf("RenderStringE(%q) = err %v, want a TemplateError", tc.in, err)
+			continue
+		}
+
+		if te.Name != "tmpl" || te.Line != tc.line || te.Column != tc.column || te.Err == nil {
+			t.Errorf("RenderStringE(%q) = %q %d:%d, want %q %d:%d", tc.in, te.Name, te.Line, te.Column, "tmpl",
+				tc.line, tc.column)
+		}
+	}
+}
+
+func TestRenderStringPanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RenderString() = expected panic")
+		}
+	}()
+
+	RenderString("{{ .name | nope }}", nil)
+}
+
+type testRenderMap struct {
+	templates map[string]string
+	out       map[string]string
+	err       string
+}
+
+//nolint:funlen
+func TestRenderMap(t *testing.T) {
+	t.Parallel()
+
+	tests := []testRenderMap{
+		{
+			map[string]string{"a": "{{ .name }}", "b": "{{ .a }}-x"},
+			map[string]string{"a": "repo", "b": "repo-x"},
+			"",
+		},
+		{
+			map[string]string{"c": "{{ $.b }}/{{ .a }}", "b": "{{ .a }}-x", "a": "{{ .name | printf \"%s.git\" }}"},
+			map[string]string{"a": "repo.git", "b": "repo.git-x", "c": "repo.git-x/repo.git"},
+			"",
+		},
+		{
+			map[string]string{"name": "{{ .name }}-x", "b": "{{ .name }}"},
+			map[string]string{"name": "repo-x", "b": "repo-x"},
+			"",
+		},
+		{
+			map[string]string{"a": "{{ if .b }}{{ .b }}{{ else }}none{{ end }}", "b": "{{ .name }}"},
+			map[string]string{"a": "repo", "b": "repo"},
+			"",
+		},
+		{
+			map[string]string{"a": "{{ .b }}", "b": "{{ .c }}", "c": "{{ .a }}"},
+			nil,
+			"cyclic template variables: a -> b -> c -> a",
+		},
+		{
+			map[string]string{"a": "{{ .a }}"},
+			nil,
+			"cyclic template variables: a -> a",
+		},
+		{
+			map[string]string{"a": "{{ .b }", "b": "{{ .name }}"},
+			nil,
+			`template "a" line 1: unexpected "}" in operand`,
+		},
+	}
+
+	for _, tc := range tests {
+		vars := map[string]string{"name": "repo"}
+
+		err := RenderMap(tc.templates, vars)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("RenderMap(%q) = err %v, want %q", tc.templates, err, tc.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("RenderMap(%q) = unexpected err %q", tc.templates, err)
+			continue
+		}
+
+		for k, want := range tc.out {
+			if vars[k] != want {
+				t.Errorf("RenderMap(%q) = %s=%q, want %q", tc.templates, k, vars[k], want)
+			}
+		}
+	}
+}
+
+func TestParseTemplates(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]map[string]string{
+		`{{ define "clone" }}git clone {{ .source }} {{ .name }}{{ end }}
+{{- define "cd" -}}
+cd {{ .name }} && {{ template "clone" . }}
+{{- end }}
+`: {"clone": "git clone {{.source}} {{.name}}", "cd": `cd {{.name}} && {{template "clone" .}}`},
+
+		`# Snippets
+
+clone='git clone {{ .source }} {{ .name }}'
+cd="cd {{ .name }}"
+`: {"clone": "git clone {{ .source }} {{ .name }}", "cd": "cd {{ .name }}"},
+
+		"": {},
+	}
+
+	for in, want := range tests {
+		got, err := ParseTemplates("snippets", in)
+		if err != nil {
+			t.Errorf("ParseTemplates(%q) = unexpected err %q", in, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseTemplates(%q) = %q, want %q", in, got, want)
+		}
+
+		vars := map[string]string{"name": "repo", "source": "https://example.com/repo"}
+		if err := RenderMap(got, vars); err != nil {
+			t.Errorf("RenderMap(%q) = unexpected err %q", got, err)
+		}
+	}
+
+	for in, line := range map[string]int{
+		`{{ define "x" }}{{ .name }}{{ end }} extra`:  0,
+		`{{ define "x" }}{{ .name | nope }}{{ end }}`: 1,
+		"a='x'\n\nb 'y'": 3,
+	} {
+		_, err := ParseTemplates("snippets", in)
+
+		var te *TemplateError
+		if !errors.As(err, &te) || te.Line != line {
+			t.Errorf("ParseTemplates(%q) = err %v, want a TemplateError at line %d", in, err, line)
+		}
+	}
+}
+
+type testData map[string]interface{}
+
+func (d testData) Twice(s string) string {
+	return s + s
+}
+
+func TestRenderData(t *testing.T) {
+	t.Parallel()
+
+	data := testData{"name": "repo", "n": 2}
+
+	err := RenderData(map[string]string{"a": `{{ .Twice .name }}-{{ .n }}`, "b": `{{ .a | upper }}`}, data)
+	if err != nil || data["a"] != "reporepo-2" || data["b"] != "REPOREPO-2" {
+		t.Errorf("RenderData() = %q, %v", data, err)
+	}
+
+	if err := RenderData(map[string]string{"a": "x"}, map[string]int{}); err == nil {
+		t.Errorf("RenderData() = expected error for unsupported data")
+	}
+}